@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"Auth-Service/api/problem"
+	"Auth-Service/genproto/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary batch get users
+// @Description Returns the compact public profiles of up to 100 users given by id or username, in no particular order. Ids and usernames matching no active user are listed separately.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param input body users.BatchGetUsersRequest true "Ids and usernames"
+// @Success 200 {object} users.BatchGetUsersResponse
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /users/batch [post]
+func (h *Handler) BatchGetUsers(ctx *gin.Context) {
+	var req users.BatchGetUsersRequest
+	if !bindValid(ctx, &req) {
+		return
+	}
+
+	res, err := h.Summaries.BatchGetUsers(ctx, &req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"Auth-Service/api/problem"
+	"Auth-Service/genproto/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Security ApiKeyAuth
+// @Summary block user
+// @Description Blocks another user. Follows between the two are removed both ways, and neither can follow the other or see the blocker's profile until the block is lifted.
+// @Tags users
+// @Param user_id path string true "user_id"
+// @Success 200 {object} users.RelationshipResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/{user_id}/block [post]
+func (h *Handler) BlockUser(ctx *gin.Context) {
+	h.changeRelationship(ctx, h.UsersRepo.BlockUser)
+}
+
+// @Security ApiKeyAuth
+// @Summary unblock user
+// @Description Lifts a block. Unblocking someone you did not block succeeds without changes.
+// @Tags users
+// @Param user_id path string true "user_id"
+// @Success 200 {object} users.RelationshipResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/{user_id}/block [delete]
+func (h *Handler) UnblockUser(ctx *gin.Context) {
+	h.changeRelationship(ctx, h.UsersRepo.UnblockUser)
+}
+
+// @Security ApiKeyAuth
+// @Summary mute user
+// @Description Hides another user's content from you without them knowing
+// @Tags users
+// @Param user_id path string true "user_id"
+// @Success 200 {object} users.RelationshipResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/{user_id}/mute [post]
+func (h *Handler) MuteUser(ctx *gin.Context) {
+	h.changeRelationship(ctx, h.UsersRepo.MuteUser)
+}
+
+// @Security ApiKeyAuth
+// @Summary unmute user
+// @Description Shows a muted user's content again
+// @Tags users
+// @Param user_id path string true "user_id"
+// @Success 200 {object} users.RelationshipResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/{user_id}/mute [delete]
+func (h *Handler) UnmuteUser(ctx *gin.Context) {
+	h.changeRelationship(ctx, h.UsersRepo.UnmuteUser)
+}
+
+func (h *Handler) changeRelationship(ctx *gin.Context, change func(context.Context, *users.RelationshipRequest) (*users.RelationshipResponse, error)) {
+	req := &users.RelationshipRequest{
+		UserId:   ctx.GetString("user_id"),
+		TargetId: ctx.Param("user_id"),
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := change(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Security ApiKeyAuth
+// @Summary list blocked users
+// @Description Lists the ids of the users the authenticated user blocked, most recent first
+// @Tags users
+// @Success 200 {object} users.UserIdsResponse
+// @Failure 401 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/blocks [get]
+func (h *Handler) ListBlockedUsers(ctx *gin.Context) {
+	h.listUserIds(ctx, h.UsersRepo.ListBlockedUsers)
+}
+
+// @Security ApiKeyAuth
+// @Summary list muted users
+// @Description Lists the ids of the users the authenticated user muted, most recent first
+// @Tags users
+// @Success 200 {object} users.UserIdsResponse
+// @Failure 401 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/mutes [get]
+func (h *Handler) ListMutedUsers(ctx *gin.Context) {
+	h.listUserIds(ctx, h.UsersRepo.ListMutedUsers)
+}
+
+func (h *Handler) listUserIds(ctx *gin.Context, list func(context.Context, *users.UserListRequest) (*users.UserIdsResponse, error)) {
+	req := &users.UserListRequest{UserId: ctx.GetString("user_id")}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := list(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
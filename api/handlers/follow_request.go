@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"Auth-Service/api/problem"
+	"Auth-Service/genproto/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Security ApiKeyAuth
+// @Summary list follow requests
+// @Description Lists the follow requests waiting for the authenticated user to approve, oldest first
+// @Tags users
+// @Param limit query int false "Number of requests to fetch"
+// @Param page query int false "Page, starting at 1"
+// @Success 200 {object} users.FollowRequestsResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/follow-requests [get]
+func (h *Handler) ListFollowRequests(ctx *gin.Context) {
+	limit, ok := queryInt32(ctx, "limit", 10)
+	if !ok {
+		return
+	}
+	page, ok := queryInt32(ctx, "page", 1)
+	if !ok {
+		return
+	}
+
+	req := &users.FollowRequestsRequest{UserId: ctx.GetString("user_id"), Page: page, Limit: limit}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.ListFollowRequests(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Security ApiKeyAuth
+// @Summary approve follow request
+// @Description Lets the given user follow the authenticated user and tells them so
+// @Tags users
+// @Param user_id path string true "id of the user who asked to follow"
+// @Success 200 {object} users.FollowRequestActionResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/follow-requests/{user_id}/approve [post]
+func (h *Handler) ApproveFollowRequest(ctx *gin.Context) {
+	h.answerFollowRequest(ctx, h.UsersRepo.ApproveFollowRequest)
+}
+
+// @Security ApiKeyAuth
+// @Summary reject follow request
+// @Description Drops the given user's follow request without telling them
+// @Tags users
+// @Param user_id path string true "id of the user who asked to follow"
+// @Success 200 {object} users.FollowRequestActionResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/follow-requests/{user_id}/reject [post]
+func (h *Handler) RejectFollowRequest(ctx *gin.Context) {
+	h.answerFollowRequest(ctx, h.UsersRepo.RejectFollowRequest)
+}
+
+func (h *Handler) answerFollowRequest(ctx *gin.Context, answer func(context.Context, *users.FollowRequestAction) (*users.FollowRequestActionResponse, error)) {
+	req := &users.FollowRequestAction{
+		UserId:      ctx.GetString("user_id"),
+		RequesterId: ctx.Param("user_id"),
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := answer(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
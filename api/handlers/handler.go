@@ -1,18 +1,35 @@
 package handlers
 
 import (
+	"Auth-Service/api/token"
+	"Auth-Service/health"
+	"Auth-Service/logger"
 	"Auth-Service/storage/postgres"
+	"Auth-Service/storage/redis"
 
+	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
 type Handler struct {
 	UsersRepo *postgres.UserRepository
+	Summaries *redis.UserSummaries
+	Health    *health.Checker
+	Tokens    token.Cutoffs
 	Log          *zap.Logger
 }
 
-func NewHandler(users *postgres.UserRepository, log *zap.Logger) *Handler {
+func NewHandler(users *postgres.UserRepository, summaries *redis.UserSummaries, tokens token.Cutoffs, health *health.Checker, log *zap.Logger) *Handler {
 	return &Handler{
 		UsersRepo: users,
+		Summaries: summaries,
+		Health:    health,
+		Tokens:    tokens,
 		Log:          log}
 }
+
+// log returns the request-scoped logger, which carries the request ID, route
+// and, behind AuthMiddleware, the caller's user ID.
+func (h *Handler) log(ctx *gin.Context) *zap.Logger {
+	return logger.FromContext(ctx.Request.Context(), h.Log)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"Auth-Service/api/problem"
+	"Auth-Service/apperrors"
+	"Auth-Service/genproto/users"
+	"Auth-Service/metrics"
+	"Auth-Service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Security ApiKeyAuth
+// @Summary report user
+// @Description Reports another user to the moderators. reason is one of fake_profile, harassment, impersonation, inappropriate_content, other or spam.
+// @Tags Moderation
+// @Accept json
+// @Produce json
+// @Param user_id path string true "id of the reported user"
+// @Param input body models.ReportRequest true "Report"
+// @Success 201 {object} users.Report
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/{user_id}/report [post]
+func (h *Handler) ReportUser(ctx *gin.Context) {
+	var body models.ReportRequest
+	if !bindValid(ctx, &body) {
+		return
+	}
+
+	req := &users.ReportUserRequest{
+		ReporterId: ctx.GetString("user_id"),
+		UserId:     ctx.Param("user_id"),
+		Reason:     body.Reason,
+		Details:    body.Details,
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.ReportUser(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, res)
+}
+
+// @Security ApiKeyAuth
+// @Summary list reports
+// @Description Lists reports oldest first for moderators, the unresolved ones unless status is given
+// @Tags Moderation
+// @Produce json
+// @Param status query string false "open, claimed or resolved"
+// @Param page query int false "Page, starting at 1"
+// @Param limit query int false "Page size"
+// @Success 200 {object} users.ListReportsResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/moderation/reports [get]
+func (h *Handler) ListReports(ctx *gin.Context) {
+	page, ok := queryInt32(ctx, "page", 1)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt32(ctx, "limit", 10)
+	if !ok {
+		return
+	}
+
+	req := &users.ListReportsRequest{
+		ModeratorId: ctx.GetString("user_id"),
+		Status:      ctx.Query("status"),
+		Page:        page,
+		Limit:       limit,
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.ListReports(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Security ApiKeyAuth
+// @Summary claim report
+// @Description Assigns an open report to the calling moderator so others leave it alone
+// @Tags Moderation
+// @Produce json
+// @Param id path string true "report id"
+// @Success 200 {object} users.Report
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/moderation/reports/{id}/claim [post]
+func (h *Handler) ClaimReport(ctx *gin.Context) {
+	req := &users.ClaimReportRequest{
+		ModeratorId: ctx.GetString("user_id"),
+		ReportId:    ctx.Param("id"),
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.ClaimReport(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Security ApiKeyAuth
+// @Summary resolve report
+// @Description Closes a report with an action: dismiss, warn, suspend for suspend_for (such as "72h") or ban. Banned and suspended users cannot log in or refresh tokens. The reported user is emailed about anything but a dismissal.
+// @Tags Moderation
+// @Accept json
+// @Produce json
+// @Param id path string true "report id"
+// @Param input body models.ResolveReportRequest true "Decision"
+// @Success 200 {object} users.Report
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/moderation/reports/{id}/resolve [post]
+func (h *Handler) ResolveReport(ctx *gin.Context) {
+	var body models.ResolveReportRequest
+	if !bindValid(ctx, &body) {
+		return
+	}
+
+	var suspendFor time.Duration
+	if body.SuspendFor != "" {
+		d, err := time.ParseDuration(body.SuspendFor)
+		if err != nil {
+			problem.Render(ctx, &apperrors.Error{
+				Kind:    apperrors.KindValidation,
+				Message: "invalid request payload",
+				Fields:  []apperrors.FieldViolation{{Field: "suspend_for", Description: "must be a duration such as 72h"}},
+			})
+			return
+		}
+		suspendFor = d
+	}
+
+	req := &users.ResolveReportRequest{
+		ModeratorId:    ctx.GetString("user_id"),
+		ReportId:       ctx.Param("id"),
+		Action:         body.Action,
+		SuspendSeconds: int64(suspendFor / time.Second),
+		Note:           body.Note,
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.ResolveReport(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	metrics.ModerationActions.WithLabelValues(req.Action).Inc()
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+
+	"Auth-Service/api/problem"
+	"Auth-Service/genproto/users"
+	"Auth-Service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List notifications
+// @Description Lists the notifications of the authenticated user, newest first, with the number of unread ones
+// @Security ApiKeyAuth
+// @Tags Notifications
+// @Produce json
+// @Param unread query bool false "Only unread notifications"
+// @Param page query int false "Page, starting at 1"
+// @Param limit query int false "Page size"
+// @Success 200 {object} users.ListNotificationsResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Router /user/notifications [get]
+func (h *Handler) ListNotifications(ctx *gin.Context) {
+	page, ok := queryInt32(ctx, "page", 1)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt32(ctx, "limit", 10)
+	if !ok {
+		return
+	}
+
+	req := &users.ListNotificationsRequest{
+		UserId:     ctx.GetString("user_id"),
+		UnreadOnly: ctx.Query("unread") == "true",
+		Page:       page,
+		Limit:      limit,
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.ListNotifications(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary Mark notifications read
+// @Description Marks the given notifications of the authenticated user as read, or all of them when ids is empty
+// @Security ApiKeyAuth
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Param input body models.MarkNotificationsReadRequest false "Notification ids"
+// @Success 200 {object} users.MarkNotificationsReadResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Router /user/notifications/read [post]
+func (h *Handler) MarkNotificationsRead(ctx *gin.Context) {
+	var body models.MarkNotificationsReadRequest
+	if ctx.Request.ContentLength != 0 && !bindValid(ctx, &body) {
+		return
+	}
+	h.markNotificationsRead(ctx, body.IDs)
+}
+
+// @Summary Mark a notification read
+// @Description Marks one notification of the authenticated user as read
+// @Security ApiKeyAuth
+// @Tags Notifications
+// @Produce json
+// @Param id path string true "Notification ID"
+// @Success 200 {object} users.MarkNotificationsReadResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Router /user/notifications/{id}/read [post]
+func (h *Handler) MarkNotificationRead(ctx *gin.Context) {
+	h.markNotificationsRead(ctx, []string{ctx.Param("id")})
+}
+
+func (h *Handler) markNotificationsRead(ctx *gin.Context, ids []string) {
+	req := &users.MarkNotificationsReadRequest{UserId: ctx.GetString("user_id"), Ids: ids}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.MarkNotificationsRead(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary Get notification preferences
+// @Description Returns how the authenticated user is notified about each event type
+// @Security ApiKeyAuth
+// @Tags Notifications
+// @Produce json
+// @Success 200 {object} users.NotificationPreferencesResponse
+// @Failure 401 {object} models.Problem
+// @Router /user/notifications/preferences [get]
+func (h *Handler) NotificationPreferences(ctx *gin.Context) {
+	req := &users.NotificationPreferencesRequest{UserId: ctx.GetString("user_id")}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.GetNotificationPreferences(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary Update notification preferences
+// @Description Sets in-app and email delivery per event type; turning both off silences the event. Event types left out keep their setting.
+// @Security ApiKeyAuth
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Param input body models.NotificationPreferencesRequest true "Preferences"
+// @Success 200 {object} users.NotificationPreferencesResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Router /user/notifications/preferences [put]
+func (h *Handler) UpdateNotificationPreferences(ctx *gin.Context) {
+	var body models.NotificationPreferencesRequest
+	if !bindValid(ctx, &body) {
+		return
+	}
+
+	req := &users.UpdateNotificationPreferencesRequest{UserId: ctx.GetString("user_id")}
+	for _, p := range body.Preferences {
+		req.Preferences = append(req.Preferences, &users.NotificationPreference{Type: p.Type, InApp: p.InApp, Email: p.Email})
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.UpdateNotificationPreferences(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"Auth-Service/api/problem"
+	"Auth-Service/genproto/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary get user by username
+// @Description Returns the public profile, without email, of the user holding username. A username given up recently still finds its former owner, with redirected set and the current username. Private accounts only show id, username, full_name and counts.
+// @Tags User
+// @Produce json
+// @Param username path string true "Username"
+// @Success 200 {object} users.PublicProfile
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /users/by-username/{username} [get]
+func (h *Handler) GetUserByUsername(ctx *gin.Context) {
+	req := &users.GetUserByUsernameRequest{Username: ctx.Param("username")}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.GetUserByUsername(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"Auth-Service/api/problem"
+	"Auth-Service/genproto/users"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Security ApiKeyAuth
+// @Summary search users
+// @Description Finds users whose username or full name starts with or resembles q. Pass next_cursor of a page as cursor to get the next one; total counts every match.
+// @Tags User
+// @Produce json
+// @Param q query string false "Search text"
+// @Param min_countries_visited query int false "Only users who visited at least this many countries"
+// @Param sort query string false "relevance (default with q), username (default without), newest or countries_visited"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Page size"
+// @Success 200 {object} users.GetUsersResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /users [get]
+func (h *Handler) SearchUsers(ctx *gin.Context) {
+	minCountries, ok := queryInt32(ctx, "min_countries_visited", 0)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt32(ctx, "limit", 10)
+	if !ok {
+		return
+	}
+
+	req := &users.GetUsersRequest{
+		Query:               ctx.Query("q"),
+		MinCountriesVisited: minCountries,
+		Sort:                ctx.Query("sort"),
+		Cursor:              ctx.Query("cursor"),
+		Limit:               limit,
+		ViewerId:            ctx.GetString("user_id"),
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.GetUsers(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
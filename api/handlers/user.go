@@ -1,50 +1,45 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
-	"strings"
 
+	"Auth-Service/api/problem"
 	"Auth-Service/api/token"
+	"Auth-Service/apperrors"
 	"Auth-Service/genproto/users"
+	"Auth-Service/metrics"
 	"Auth-Service/models"
+	"Auth-Service/validation"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
-	"github.com/google/uuid"
-	"go.uber.org/zap"
 )
 
 // Register handles user registration.
 // @Summary Register a new user
 // @Description Register a new user with username and password and email
-// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param input body users.RegisterRequest true "Registration details"
 // @Success 201 {object} users.RegisterResponse
-// @Failure 400 {object} string "bad request"
-// @Failure 500 {object} string "internal status error"
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /auth/register [post]
 func (h *Handler) Register(ctx *gin.Context) {
-	var request models.RegisterRequest
-	if err := ctx.ShouldBindJSON(&request); err != nil {
-		h.Log.Error("Failed to bind JSON", zap.Error(err))
-		ctx.JSON(http.StatusBadRequest, models.Failed{Message: "Invalid request payload", Error: err.Error()})
+	var request users.RegisterRequest
+	if !bindValid(ctx, &request) {
 		return
 	}
 
-	response, err := h.UsersRepo.Register(ctx, &users.RegisterRequest{
-		Username: request.Username,
-		Password: request.Password,
-		Email:    request.Email,
-		FullName: request.FullName,
-	})
+	response, err := h.UsersRepo.Register(ctx, &request)
+	metrics.Registrations.WithLabelValues(metrics.Outcome(err)).Inc()
 	if err != nil {
-		h.Log.Error("Failed to create user", zap.Error(err))
-		ctx.JSON(http.StatusInternalServerError, models.Failed{Message: "Failed to create user", Error: err.Error()})
+		problem.Render(ctx, err)
 		return
 	}
 
@@ -53,126 +48,393 @@ func (h *Handler) Register(ctx *gin.Context) {
 
 // Login handles user login.
 // @Summary Login a user
-// @Description Login a user with username and password
-// @Security BearerAuth
+// @Description Login a user with username or email and password. Both are matched case-insensitively.
+// @Security ApiKeyAuth
 // @Tags Auth
 // @Accept json
 // @Produce json
-// @Param input body models.LoginRequest true "Login details"
+// @Param input body users.LoginRequest true "Login details"
 // @Success 200 {object} models.Tokens
-// @Failure 400 {object} models.Failed
-// @Failure 500 {object} models.Failed
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /auth/login [post]
 func (h Handler) Login(ctx *gin.Context) {
-	h.Log.Info("Login is working")
+	h.log(ctx).Info("Login is working")
 	req := users.LoginRequest{}
 
-	if err := ctx.BindJSON(&req); err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(http.StatusBadRequest, gin.H{"error1": err.Error()})
+	if !bindValid(ctx, &req) {
+		return
 	}
 
 	res, err := h.UsersRepo.Login(ctx, &req)
+	metrics.Logins.WithLabelValues(loginOutcome(err)).Inc()
 	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(500, gin.H{"error2": err.Error()})
+		problem.Render(ctx, err)
 		return
 	}
 	var toke users.Token
-	err = token.GeneratedAccessJWTToken(res, &toke)
-
-	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(500, gin.H{"error3": err.Error()})
+	if err = token.GeneratedAccessJWTToken(res, &toke); err != nil {
+		problem.Render(ctx, apperrors.Internal(err))
+		return
 	}
-	err = token.GeneratedRefreshJWTToken(res, &toke)
-	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(500, gin.H{"error4": err.Error()})
+	if err = token.GeneratedRefreshJWTToken(res, &toke); err != nil {
+		problem.Render(ctx, apperrors.Internal(err))
+		return
 	}
 
 	ctx.JSON(http.StatusOK, &toke)
-	h.Log.Info("login is succesfully ended")
+	h.log(ctx).Info("login is succesfully ended")
+
+}
 
+func loginOutcome(err error) string {
+	if errors.Is(err, apperrors.ErrInvalidCredentials) {
+		return metrics.OutcomeInvalidCredentials
+	}
+	return metrics.Outcome(err)
 }
 
 // @Summary Refresh token
-// @Description it changes your access token
-// @Security BearerAuth
+// @Description it changes your access token. Banned, suspended and deactivated accounts get 403, and revoked refresh tokens get 401.
+// @Security ApiKeyAuth
 // @Tags Auth
 // @Param userinfo body users.CheckRefreshTokenRequest true "token"
 // @Success 200 {object} users.Token
-// @Failure 400 {object} string "Invalid date"
-// @Failure 401 {object} string "Invalid token"
-// @Failure 500 {object} string "error while reading from server"
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 403 {object} models.Problem
 // @Router /auth/refresh [post]
 func (h Handler) Refresh(ctx *gin.Context) {
-	h.Log.Info("Refresh is working")
+	h.log(ctx).Info("Refresh is working")
 	req := users.CheckRefreshTokenRequest{}
-	if err := ctx.BindJSON(&req); err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindValid(ctx, &req) {
+		return
 	}
-	_, err := token.ValidateRefreshToken(req.RefreshToken)
-	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	claims, err := token.ExtractRefreshClaim(req.RefreshToken)
+	if err != nil || claims == nil {
+		metrics.Refreshes.WithLabelValues(metrics.OutcomeInvalidToken).Inc()
+		problem.Render(ctx, apperrors.Unauthenticated("invalid refresh token", err))
+		return
 	}
-	id, err := token.GetUserIdFromRefreshToken(req.RefreshToken)
-	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	id, _ := (*claims)["user_id"].(string)
+	if err = token.CheckNotBefore(ctx, h.Tokens, *claims); err != nil {
+		outcome := metrics.OutcomeFailure
+		if errors.Is(err, token.ErrRevoked) {
+			outcome = metrics.OutcomeInvalidToken
+		}
+		metrics.Refreshes.WithLabelValues(outcome).Inc()
+		problem.Render(ctx, token.RevocationError(err))
+		return
+	}
+	if err = h.UsersRepo.CheckAccount(ctx, id); err != nil {
+		metrics.Refreshes.WithLabelValues(metrics.Outcome(err)).Inc()
+		problem.Render(ctx, err)
+		return
 	}
 	res := users.Token{RefreshToken: req.RefreshToken}
 
 	err = token.GeneratedAccessJWTToken(&users.RegisterResponse{Id: id}, &res)
+	metrics.Refreshes.WithLabelValues(metrics.Outcome(err)).Inc()
 	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		problem.Render(ctx, apperrors.Internal(err))
+		return
 	}
 	ctx.JSON(http.StatusOK, &res)
 }
 
-// ValidateToken validates the JWT token from Authorization header.
-func (h *Handler) ValidateToken(ctx *gin.Context) (jwt.MapClaims, error) {
-	tokenString := ctx.GetHeader("Authorization")
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+// @Summary Check username availability
+// @Description Reports whether a username can be registered and suggests alternatives when it is taken or reserved
+// @Tags Auth
+// @Produce json
+// @Param username query string true "Username to check"
+// @Success 200 {object} users.CheckUsernameResponse
+// @Failure 400 {object} models.Problem
+// @Router /auth/username-available [get]
+func (h *Handler) UsernameAvailable(ctx *gin.Context) {
+	req := &users.CheckUsernameRequest{Username: ctx.Query("username")}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.CheckUsername(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary Request a password reset
+// @Description Emails a single-use password reset link if the address belongs to an account
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body users.ResetPasswordRequest true "Account email"
+// @Success 200 {object} users.ResetPasswordResponse
+// @Failure 400 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Router /auth/reset-password [post]
+func (h *Handler) ResetPassword(ctx *gin.Context) {
+	var req users.ResetPasswordRequest
+	if !bindValid(ctx, &req) {
+		return
+	}
+
+	res, err := h.UsersRepo.ResetPassword(ctx, &req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary Confirm a password reset
+// @Description Sets a new password using the token from the reset email
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body users.ConfirmPasswordResetRequest true "Reset token and new password"
+// @Success 200 {object} users.ChangePasswordResponse
+// @Failure 400 {object} models.Problem
+// @Router /auth/reset-password/confirm [post]
+func (h *Handler) ConfirmPasswordReset(ctx *gin.Context) {
+	var req users.ConfirmPasswordResetRequest
+	if !bindValid(ctx, &req) {
+		return
+	}
+
+	res, err := h.UsersRepo.ConfirmPasswordReset(ctx, &req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary Change password
+// @Description Changes the password of the authenticated user
+// @Security ApiKeyAuth
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param input body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} users.ChangePasswordResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Router /user/password [put]
+func (h *Handler) ChangePassword(ctx *gin.Context) {
+	var body models.ChangePasswordRequest
+	if !bindValid(ctx, &body) {
+		return
+	}
+
+	req := &users.ChangePasswordRequest{
+		UserId:          ctx.GetString("user_id"),
+		CurrentPassword: body.CurrentPassword,
+		NewPassword:     body.NewPassword,
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.ChangePassword(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary Change username
+// @Description Changes the username of the authenticated user. The old username keeps pointing to the account for a grace period and is held from other users for longer.
+// @Security ApiKeyAuth
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param input body models.ChangeUsernameRequest true "New username"
+// @Success 200 {object} users.ChangeUsernameResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Router /user/username [put]
+func (h *Handler) ChangeUsername(ctx *gin.Context) {
+	var body models.ChangeUsernameRequest
+	if !bindValid(ctx, &body) {
+		return
+	}
+
+	req := &users.ChangeUsernameRequest{UserId: ctx.GetString("user_id"), Username: body.Username}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.ChangeUsername(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Summary Change email
+// @Description Starts an email change for the authenticated user. The new address receives a confirmation link and the current one a notice with a cancel link; the address changes only after confirmation.
+// @Security ApiKeyAuth
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param input body models.ChangeEmailRequest true "New email and current password"
+// @Success 202 {object} users.EmailChangeResponse
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Router /user/email [post]
+func (h *Handler) RequestEmailChange(ctx *gin.Context) {
+	var body models.ChangeEmailRequest
+	if !bindValid(ctx, &body) {
+		return
+	}
+
+	req := &users.RequestEmailChangeRequest{
+		UserId:   ctx.GetString("user_id"),
+		NewEmail: body.NewEmail,
+		Password: body.Password,
+	}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := h.UsersRepo.RequestEmailChange(ctx, req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, res)
+}
+
+// @Summary Confirm email change
+// @Description Confirms a pending email change with the token sent to the new address
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body users.EmailChangeTokenRequest true "Confirmation token"
+// @Success 200 {object} users.EmailChangeResponse
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Router /auth/email-change/confirm [post]
+func (h *Handler) ConfirmEmailChange(ctx *gin.Context) {
+	var req users.EmailChangeTokenRequest
+	if !bindValid(ctx, &req) {
+		return
+	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte("my_secret_key"), nil
-	})
+	res, err := h.UsersRepo.ConfirmEmailChange(ctx, &req)
 	if err != nil {
-		return nil, err
+		problem.Render(ctx, err)
+		return
 	}
+	ctx.JSON(http.StatusOK, res)
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+// @Summary Cancel email change
+// @Description Cancels a pending email change with the token sent to the old address
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body users.EmailChangeTokenRequest true "Cancel token"
+// @Success 200 {object} users.EmailChangeResponse
+// @Failure 400 {object} models.Problem
+// @Router /auth/email-change/cancel [post]
+func (h *Handler) CancelEmailChange(ctx *gin.Context) {
+	var req users.EmailChangeTokenRequest
+	if !bindValid(ctx, &req) {
+		return
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	res, err := h.UsersRepo.CancelEmailChange(ctx, &req)
+	if err != nil {
+		problem.Render(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// requireSelf rejects requests where the authenticated user differs from
+// the user_id path parameter.
+func requireSelf(ctx *gin.Context) (string, error) {
+	userID := ctx.Param("user_id")
+	if ctx.GetString("user_id") != userID {
+		return "", apperrors.Forbidden("you can only modify your own account")
+	}
+	return userID, nil
+}
+
+// bindValid decodes the JSON body into req and validates it, rendering a
+// problem and returning false when either step fails.
+func bindValid(ctx *gin.Context, req any) bool {
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		problem.Render(ctx, &apperrors.Error{
+			Kind:    apperrors.KindValidation,
+			Message: "invalid request payload",
+			Fields:  []apperrors.FieldViolation{{Field: "body", Description: err.Error()}},
+			Err:     err,
+		})
+		return false
+	}
+	return valid(ctx, req)
+}
+
+// valid renders a validation problem and returns false when req breaks the
+// rules declared for it in package validation.
+func valid(ctx *gin.Context, req any) bool {
+	if err := validation.Request(req); err != nil {
+		problem.Render(ctx, err)
+		return false
+	}
+	return true
+}
+
+// queryInt32 reads an optional integer query parameter, rendering a
+// validation problem and returning false when it is not a number.
+func queryInt32(ctx *gin.Context, name string, def int32) (int32, bool) {
+	v := ctx.Query(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		problem.Render(ctx, apperrors.Validation(apperrors.FieldViolation{Field: name, Description: "must be an integer"}))
+		return 0, false
+	}
+	return int32(n), true
 }
 
 // Profile retrieves user profile details.
 // @Summary Get user profile
 // @Description Retrieve user profile details
-// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Tags User
 // @Accept json
 // @Produce json
 // @Param user_id path string true "User ID"
-// @Success 200 {object} models.ProfileResponse
-// @Failure 401 {object} models.Failed
-// @Failure 500 {object} models.Failed
+// @Success 200 {object} users.ProfileResponse
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /user/profile/{user_id} [get]
 func (h *Handler) Profile(ctx *gin.Context) {
-	userID := ctx.Param("user_id")
-	fmt.Println(userID)
+	request := &users.ProfileRequest{UserId: ctx.Param("user_id"), ViewerId: ctx.GetString("user_id")}
+	if !valid(ctx, request) {
+		return
+	}
 
-	request := &users.ProfileRequest{UserId: userID}
 	response, err := h.UsersRepo.Profile(ctx, request)
 	if err != nil {
-		h.Log.Error("Failed to fetch profile", zap.Error(err))
-		ctx.JSON(http.StatusInternalServerError, models.Failed{Message: "Failed to fetch profile", Error: err.Error()})
+		problem.Render(ctx, err)
 		return
 	}
 
@@ -181,48 +443,45 @@ func (h *Handler) Profile(ctx *gin.Context) {
 
 // UpdateProfile updates user profile details.
 // @Summary Update user profile
-// @Description Update user profile details
-// @Security BearerAuth
+// @Description Update user profile details. Making the account public approves every pending follow request.
+// @Security ApiKeyAuth
 // @Tags User
 // @Accept json
 // @Produce json
 // @Param user_id path string true "User ID"
 // @Param input body models.UpdateProfileRequest true "Update details"
 // @Success 200 {object} models.ProfileResponse
-// @Failure 400 {object} models.Failed
-// @Failure 401 {object} models.Failed
-// @Failure 500 {object} models.Failed
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /user/profileUpdate/{user_id} [put]
 func (h *Handler) UpdateProfile(ctx *gin.Context) {
-	userID := ctx.Param("user_id")
-
-	claims, err := h.ValidateToken(ctx)
+	userID, err := requireSelf(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, models.Failed{Message: "Unauthorized", Error: err.Error()})
+		problem.Render(ctx, err)
 		return
 	}
 
-	if claims["sub"] != userID {
-		ctx.JSON(http.StatusUnauthorized, models.Failed{Message: "Unauthorized access to profile"})
+	var request models.UpdateProfileRequest
+	if !bindValid(ctx, &request) {
 		return
 	}
 
-	var request models.UpdateProfileRequest
-	if err := ctx.ShouldBindJSON(&request); err != nil {
-		h.Log.Error("Failed to bind JSON", zap.Error(err))
-		ctx.JSON(http.StatusBadRequest, models.Failed{Message: "Invalid request payload", Error: err.Error()})
+	update := &users.UpdateProfileRequest{
+		Id:        userID,
+		FullName:  request.FullName,
+		Bio:       request.Bio,
+		IsPrivate: request.IsPrivate,
+	}
+	if !valid(ctx, update) {
 		return
 	}
 
-	request.UserID = userID
-	response, err := h.UsersRepo.UpdateProfile(ctx, &users.UpdateProfileRequest{
-		Id:       request.UserID,
-		FullName: request.FullName,
-		Bio:      request.Bio,
-	})
+	response, err := h.UsersRepo.UpdateProfile(ctx, update)
 	if err != nil {
-		h.Log.Error("Failed to update profile", zap.Error(err))
-		ctx.JSON(http.StatusInternalServerError, models.Failed{Message: "Failed to update profile", Error: err.Error()})
+		problem.Render(ctx, err)
 		return
 	}
 
@@ -231,125 +490,141 @@ func (h *Handler) UpdateProfile(ctx *gin.Context) {
 
 // @Summary delete user
 // @Description you can delete your profile
-// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Tags User
 // @Param user_id path string true "user_id"
 // @Success 200 {object} string
-// @Failure 400 {object} string "Invalid data"
-// @Failure 500 {object} string "error while reading from server"
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /user/users/{user_id} [delete]
 func (h Handler) Delete(ctx *gin.Context) {
-	h.Log.Info("Delete is working")
-	id := ctx.Param("user_id")
-	_, err := uuid.Parse(id)
+	h.log(ctx).Info("Delete is working")
+	id, err := requireSelf(ctx)
 	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "user id is incorrect"})
+		problem.Render(ctx, err)
 		return
 	}
 
 	_, err = h.UsersRepo.DeleteUser(ctx, &users.DeleteUserRequest{Id: id})
 	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(500, gin.H{"error": err.Error()})
+		problem.Render(ctx, err)
+		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"message": "user deleted"})
-	h.Log.Info("Delete ended")
+	h.log(ctx).Info("Delete ended")
 }
 
-// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Summary follow user
-// @Description you can follow another user
+// @Description Follows another user. Following someone you already follow succeeds without changes.
 // @Tags users
 // @Param user_id path string true "user_id"
 // @Success 200 {object} users.FollowResponce
-// @Failure 400 {object} string "Invalid data"
-// @Failure 500 {object} string "error while reading from server"
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /user/{user_id}/follow [post]
 func (h *Handler) FollowUser(ctx *gin.Context) {
-	h.Log.Info("Follow is working")
-	id := ctx.Param("user_id")
-	_, err := uuid.Parse(id)
-	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "user id is incorrect"})
+	req := users.FollowRequest{
+		FollowerId:  ctx.GetString("user_id"),
+		FollowingId: ctx.Param("user_id"),
 	}
-
-	accessToken := ctx.GetHeader("Authorization")
-	idFollower, err := token.GetUserIdFromAccessToken(accessToken)
+	if !valid(ctx, &req) {
+		return
+	}
+	res, err := h.UsersRepo.Follow(ctx, &req)
+	metrics.Follows.WithLabelValues(metrics.Outcome(err)).Inc()
 	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized"})
+		problem.Render(ctx, err)
+		return
 	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// @Security ApiKeyAuth
+// @Summary unfollow user
+// @Description Stops following another user. Unfollowing someone you do not follow succeeds without changes.
+// @Tags users
+// @Param user_id path string true "user_id"
+// @Success 200 {object} users.FollowResponce
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/{user_id}/follow [delete]
+func (h *Handler) UnfollowUser(ctx *gin.Context) {
 	req := users.FollowRequest{
-		FollowerId:  idFollower,
-		FollowingId: id,
+		FollowerId:  ctx.GetString("user_id"),
+		FollowingId: ctx.Param("user_id"),
 	}
-	res, err := h.UsersRepo.Follow(ctx, &req)
-	fmt.Println(res, err)
+	if !valid(ctx, &req) {
+		return
+	}
+	res, err := h.UsersRepo.UnfollowUser(ctx, &req)
+	metrics.Unfollows.WithLabelValues(metrics.Outcome(err)).Inc()
 	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(500, gin.H{"error": err.Error()})
+		problem.Render(ctx, err)
+		return
 	}
 	ctx.JSON(http.StatusOK, res)
-	h.Log.Info("Follow ended")
 }
 
 // @Security ApiKeyAuth
 // @Summary get followers
-// @Description you can see your followers
+// @Description Lists the users following the given user, most recent first
 // @Tags users
 // @Param user_id path string true "user_id"
-// @Param limit query string false "Number of users to fetch"
-// @Param page query string false "Number of users to omit"
+// @Param limit query int false "Number of users to fetch"
+// @Param page query int false "Page, starting at 1"
 // @Success 200 {object} users.FollowersResponce
-// @Failure 400 {object} string "Invalid data"
-// @Failure 500 {object} string "error while reading from server"
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /user/{user_id}/followers [get]
 func (h *Handler) FollowersUsers(ctx *gin.Context) {
-	h.Log.Info("Followers is working")
-	id := ctx.Param("user_id")
-	_, err := uuid.Parse(id)
-	if err != nil {
-		h.Log.Error(err.Error())
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "user id is incorrect"})
-	}
-	req := users.FollowersRequest{UserId: id}
+	h.listFollows(ctx, h.UsersRepo.FollowersUsers)
+}
 
-	limitStr := ctx.Query("limit")
-	pageStr := ctx.Query("page")
+// @Security ApiKeyAuth
+// @Summary get following
+// @Description Lists the users the given user follows, most recent first
+// @Tags users
+// @Param user_id path string true "user_id"
+// @Param limit query int false "Number of users to fetch"
+// @Param page query int false "Page, starting at 1"
+// @Success 200 {object} users.FollowersResponce
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /user/{user_id}/following [get]
+func (h *Handler) FollowingUsers(ctx *gin.Context) {
+	h.listFollows(ctx, h.UsersRepo.FollowingUsers)
+}
 
-	if limitStr != "" {
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest,
-				gin.H{"error": err.Error()})
-			h.Log.Error(err.Error())
-			return
-		}
-		req.Limit = int32(limit)
-	} else {
-		req.Limit = 10
-	}
-
-	if pageStr != "" {
-		page, err := strconv.Atoi(pageStr)
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest,
-				gin.H{"error": err.Error()})
-			h.Log.Error(err.Error())
-			return
-		}
-		req.Page = int32(page)
-	} else {
-		req.Page = 1
+func (h *Handler) listFollows(ctx *gin.Context, list func(context.Context, *users.FollowersRequest) (*users.FollowersResponce, error)) {
+	limit, ok := queryInt32(ctx, "limit", 10)
+	if !ok {
+		return
+	}
+	page, ok := queryInt32(ctx, "page", 1)
+	if !ok {
+		return
 	}
 
-	res, err := h.UsersRepo.FollowersUsers(ctx, &req)
+	req := &users.FollowersRequest{UserId: ctx.Param("user_id"), ViewerId: ctx.GetString("user_id"), Page: page, Limit: limit}
+	if !valid(ctx, req) {
+		return
+	}
+
+	res, err := list(ctx, req)
 	if err != nil {
-		h.Log.Error(err.Error())
+		problem.Render(ctx, err)
+		return
 	}
 	ctx.JSON(http.StatusOK, res)
-	h.Log.Info("Followers ended")
 }
-
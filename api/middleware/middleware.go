@@ -1,30 +1,50 @@
 package middleware
 
 import (
+	"Auth-Service/api/problem"
 	"Auth-Service/api/token"
-	"fmt"
-	"net/http"
+	"Auth-Service/apperrors"
+	"Auth-Service/logger"
+	"Auth-Service/metrics"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware accepts requests carrying a valid access token that was
+// issued after the user's cut-off in cutoffs.
+func AuthMiddleware(cutoffs token.Cutoffs) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		accessToken := c.GetHeader("Authorization")
-		fmt.Println(accessToken)
 		if accessToken == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Authorization is required",
-			})
+			metrics.TokenValidationFailures.WithLabelValues("missing").Inc()
+			problem.Render(c, apperrors.Unauthenticated("authorization is required", nil))
 			return
 		}
 
-		_, err := token.ExtractAccessClaim(accessToken)
+		claims, err := token.ExtractAccessClaim(accessToken)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
+			metrics.TokenValidationFailures.WithLabelValues(token.FailureReason(err)).Inc()
+			problem.Render(c, apperrors.Unauthenticated("invalid access token", err))
 			return
 		}
+
+		if claims == nil {
+			metrics.TokenValidationFailures.WithLabelValues("invalid").Inc()
+			problem.Render(c, apperrors.Unauthenticated("invalid access token", nil))
+			return
+		}
+
+		if err = token.CheckNotBefore(c.Request.Context(), cutoffs, *claims); err != nil {
+			problem.Render(c, token.RevocationError(err))
+			return
+		}
+
+		if userID, ok := (*claims)["user_id"].(string); ok {
+			c.Set("user_id", userID)
+			ctx := logger.With(c.Request.Context(), zap.NewNop(), zap.String("user_id", userID))
+			c.Request = c.Request.WithContext(ctx)
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"Auth-Service/logger"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequestLogger assigns every request an ID, echoes it in the X-Request-ID
+// response header and binds a logger carrying the ID and route to the request
+// context. It also writes one access log line per request.
+func RequestLogger(base *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := logger.NewRequestID(c.GetHeader(logger.RequestIDKey))
+		c.Header(logger.RequestIDKey, id)
+
+		l := base.With(
+			zap.String("request_id", id),
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+		)
+		ctx := logger.WithContext(logger.WithRequestID(c.Request.Context(), id), l)
+		c.Request = c.Request.WithContext(ctx)
+
+		start := time.Now()
+		c.Next()
+
+		fields := []zap.Field{
+			zap.Int("status", c.Writer.Status()),
+			zap.Duration("latency", time.Since(start)),
+		}
+		l = logger.FromContext(c.Request.Context(), l)
+		switch {
+		case c.Writer.Status() >= http.StatusInternalServerError:
+			l.Error("request completed", fields...)
+		case c.Writer.Status() >= http.StatusBadRequest:
+			l.Warn("request completed", fields...)
+		default:
+			l.Info("request completed", fields...)
+		}
+	}
+}
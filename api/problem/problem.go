@@ -0,0 +1,56 @@
+// Package problem renders errors as RFC 7807 problem details. It is the only
+// place that decides how an error looks on the wire for HTTP clients.
+package problem
+
+import (
+	"net/http"
+
+	"Auth-Service/apperrors"
+	"Auth-Service/logger"
+	"Auth-Service/models"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const contentType = "application/problem+json"
+
+var httpStatus = map[apperrors.Kind]int{
+	apperrors.KindInternal:           http.StatusInternalServerError,
+	apperrors.KindNotFound:           http.StatusNotFound,
+	apperrors.KindConflict:           http.StatusConflict,
+	apperrors.KindInvalidCredentials: http.StatusUnauthorized,
+	apperrors.KindUnauthenticated:    http.StatusUnauthorized,
+	apperrors.KindForbidden:          http.StatusForbidden,
+	apperrors.KindValidation:         http.StatusBadRequest,
+	apperrors.KindUnavailable:        http.StatusServiceUnavailable,
+}
+
+// Render aborts the request and writes err as a problem document. Causes of
+// internal errors are logged with the request-scoped logger and never sent to
+// the client.
+func Render(c *gin.Context, err error) {
+	e := apperrors.From(err)
+	status := httpStatus[e.Kind]
+
+	log := logger.FromContext(c.Request.Context(), zap.NewNop())
+	if status >= http.StatusInternalServerError {
+		log.Error(e.Message, zap.Error(err))
+	} else {
+		log.Debug(e.Message, zap.Error(err))
+	}
+
+	body := models.Problem{
+		Type:      "/problems/" + e.Kind.String(),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Message,
+		Instance:  c.Request.URL.Path,
+		RequestID: logger.RequestID(c.Request.Context()),
+		Errors:    e.Fields,
+	}
+
+	// gin only sets the JSON content type when none is present.
+	c.Header("Content-Type", contentType)
+	c.AbortWithStatusJSON(status, body)
+}
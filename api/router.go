@@ -4,6 +4,7 @@ import (
 	_ "Auth-Service/api/docs"
 	"Auth-Service/api/handlers"
 	"Auth-Service/api/middleware"
+	"Auth-Service/metrics"
 
 	// "Auth-Service/api/middleware"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	files "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // NewRouter @title API Service
@@ -23,8 +25,20 @@ import (
 // @in header
 // @name Authorization
 func NewRouter(handler *handlers.Handler) *gin.Engine {
-	r := gin.Default()
+	r := gin.New()
+	// Let handlers pass *gin.Context straight to the repository while still
+	// carrying the request's trace span and logger.
+	r.ContextWithFallback = true
+	r.Use(
+		gin.Recovery(),
+		otelgin.Middleware("auth-service"),
+		middleware.RequestLogger(handler.Log),
+		middleware.Metrics(),
+	)
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(files.Handler))
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/healthz", handler.Healthz)
+	r.GET("/readyz", handler.Readyz)
 
 	// API routes
 	auth := r.Group("/auth")
@@ -32,15 +46,49 @@ func NewRouter(handler *handlers.Handler) *gin.Engine {
 		auth.POST("/register", handler.Register)
 		auth.POST("/login", handler.Login)
 		auth.POST("/refresh", handler.Refresh)
+		auth.GET("/username-available", handler.UsernameAvailable)
+		auth.POST("/reset-password", handler.ResetPassword)
+		auth.POST("/reset-password/confirm", handler.ConfirmPasswordReset)
+		auth.POST("/email-change/confirm", handler.ConfirmEmailChange)
+		auth.POST("/email-change/cancel", handler.CancelEmailChange)
 	}
 	user := r.Group("/user")
-	user.Use(middleware.AuthMiddleware())
+	user.Use(middleware.AuthMiddleware(handler.Tokens))
 	{
 		user.GET("/profile/:user_id", handler.Profile)
 		user.PUT("/profileUpdate/:user_id", handler.UpdateProfile)
 		user.DELETE("/users/:user_id", handler.Delete)
-		user.POST("/user/:user_id/follow", handler.FollowUser)
-		user.GET("/user/:user_id/followers", handler.FollowersUsers)
+		user.PUT("/password", handler.ChangePassword)
+		user.PUT("/username", handler.ChangeUsername)
+		user.POST("/email", handler.RequestEmailChange)
+		user.POST("/:user_id/follow", handler.FollowUser)
+		user.DELETE("/:user_id/follow", handler.UnfollowUser)
+		user.GET("/:user_id/followers", handler.FollowersUsers)
+		user.GET("/:user_id/following", handler.FollowingUsers)
+		user.GET("/follow-requests", handler.ListFollowRequests)
+		user.POST("/follow-requests/:user_id/approve", handler.ApproveFollowRequest)
+		user.POST("/follow-requests/:user_id/reject", handler.RejectFollowRequest)
+		user.POST("/:user_id/block", handler.BlockUser)
+		user.DELETE("/:user_id/block", handler.UnblockUser)
+		user.POST("/:user_id/mute", handler.MuteUser)
+		user.DELETE("/:user_id/mute", handler.UnmuteUser)
+		user.GET("/blocks", handler.ListBlockedUsers)
+		user.GET("/mutes", handler.ListMutedUsers)
+		user.POST("/:user_id/report", handler.ReportUser)
+		user.GET("/moderation/reports", handler.ListReports)
+		user.POST("/moderation/reports/:id/claim", handler.ClaimReport)
+		user.POST("/moderation/reports/:id/resolve", handler.ResolveReport)
+		user.GET("/notifications", handler.ListNotifications)
+		user.POST("/notifications/read", handler.MarkNotificationsRead)
+		user.POST("/notifications/:id/read", handler.MarkNotificationRead)
+		user.GET("/notifications/preferences", handler.NotificationPreferences)
+		user.PUT("/notifications/preferences", handler.UpdateNotificationPreferences)
+	}
+	users := r.Group("/users")
+	{
+		users.GET("", middleware.AuthMiddleware(handler.Tokens), handler.SearchUsers)
+		users.POST("/batch", handler.BatchGetUsers)
+		users.GET("/by-username/:username", handler.GetUserByUsername)
 	}
 
 	return r
@@ -7,18 +7,17 @@ import (
 	"github.com/dgrijalva/jwt-go"
 )
 
-const (
-	newsigningkey = "my_secret_key"
-)
-
 func GeneratedRefreshJWTToken(req *pb.RegisterResponse, tok *pb.Token) error {
+	if len(refreshKey) == 0 {
+		return errNotConfigured
+	}
 	token := *jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
 	claims["user_id"] = req.Id
 	claims["iat"] = time.Now().Unix()
-	claims["exp"] = time.Now().Add(24 * time.Hour).Unix()
+	claims["exp"] = time.Now().Add(refreshTTL).Unix()
 
-	newToken, err := token.SignedString([]byte(newsigningkey))
+	newToken, err := token.SignedString(refreshKey)
 	if err != nil {
 		return err
 	}
@@ -36,9 +35,7 @@ func ValidateRefreshToken(tokenStr string) (bool, error) {
 }
 
 func ExtractRefreshClaim(tokenStr string) (*jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		return []byte(newsigningkey), nil
-	})
+	token, err := jwt.Parse(tokenStr, refreshKeyFunc)
 
 	if err != nil {
 		return nil, err
@@ -53,7 +50,7 @@ func ExtractRefreshClaim(tokenStr string) (*jwt.MapClaims, error) {
 }
 
 func GetUserIdFromRefreshToken(accessTokenString string) (string, error) {
-	refreshToken, err := jwt.Parse(accessTokenString, func(token *jwt.Token) (interface{}, error) { return []byte(newsigningkey), nil })
+	refreshToken, err := jwt.Parse(accessTokenString, refreshKeyFunc)
 	if err != nil || !refreshToken.Valid {
 		return "", err
 	}
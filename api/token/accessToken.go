@@ -2,28 +2,25 @@ package token
 
 import (
 	user "Auth-Service/genproto/users"
-	"log"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-const (
-	signingkey = "my_secret_key"
-)
-
 func GeneratedAccessJWTToken(req *user.RegisterResponse, tok *user.Token) error {
+	if len(accessKey) == 0 {
+		return errNotConfigured
+	}
 
 	token := *jwt.New(jwt.SigningMethodHS256)
 
 	claims := token.Claims.(jwt.MapClaims)
 	claims["user_id"] = req.Id
 	claims["iat"] = time.Now().Unix()
-	claims["exp"] = time.Now().Add(30 * time.Minute).Unix()
+	claims["exp"] = time.Now().Add(accessTTL).Unix()
 
-	newToken, err := token.SignedString([]byte(signingkey))
+	newToken, err := token.SignedString(accessKey)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
 
@@ -40,9 +37,7 @@ func ValidateAccessToken(tokenStr string) (bool, error) {
 }
 
 func ExtractAccessClaim(tokenStr string) (*jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		return []byte(signingkey), nil
-	})
+	token, err := jwt.Parse(tokenStr, accessKeyFunc)
 
 	if err != nil {
 		return nil, err
@@ -57,7 +52,7 @@ func ExtractAccessClaim(tokenStr string) (*jwt.MapClaims, error) {
 }
 
 func GetUserIdFromAccessToken(accessTokenString string) (string, error) {
-	refreshToken, err := jwt.Parse(accessTokenString, func(token *jwt.Token) (interface{}, error) { return []byte(signingkey), nil })
+	refreshToken, err := jwt.Parse(accessTokenString, accessKeyFunc)
 	if err != nil || !refreshToken.Valid {
 		return "", err
 	}
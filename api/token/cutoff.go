@@ -0,0 +1,42 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ErrRevoked is returned for a token issued before the user's tokens were
+// revoked.
+var ErrRevoked = errors.New("token: revoked")
+
+// Cutoffs tells since when a user's tokens are valid. A zero time means
+// none of them has been revoked.
+type Cutoffs interface {
+	NotBefore(ctx context.Context, userID string) (time.Time, error)
+}
+
+// CheckNotBefore returns ErrRevoked when the token with claims was issued at
+// or before the cut-off of its user. Token times have second precision, so a
+// token issued in the same second as the cut-off counts as revoked. A nil
+// cutoffs accepts every token.
+func CheckNotBefore(ctx context.Context, cutoffs Cutoffs, claims jwt.MapClaims) error {
+	if cutoffs == nil {
+		return nil
+	}
+	userID, _ := claims["user_id"].(string)
+	notBefore, err := cutoffs.NotBefore(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if notBefore.IsZero() {
+		return nil
+	}
+	issuedAt, ok := claims["iat"].(float64)
+	if !ok || int64(issuedAt) <= notBefore.Unix() {
+		return ErrRevoked
+	}
+	return nil
+}
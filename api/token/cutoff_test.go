@@ -0,0 +1,31 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedCutoffs map[string]time.Time
+
+func (c fixedCutoffs) NotBefore(_ context.Context, userID string) (time.Time, error) {
+	return c[userID], nil
+}
+
+func TestCheckNotBefore(t *testing.T) {
+	cutoff := time.Unix(1700000000, 0)
+	cutoffs := fixedCutoffs{"revoked": cutoff}
+	claims := func(userID string, iat int64) jwt.MapClaims {
+		// Parsed claims hold numbers as float64.
+		return jwt.MapClaims{"user_id": userID, "iat": float64(iat)}
+	}
+
+	assert.NoError(t, CheckNotBefore(context.Background(), cutoffs, claims("other", 1)))
+	assert.NoError(t, CheckNotBefore(context.Background(), cutoffs, claims("revoked", cutoff.Unix()+1)))
+	assert.ErrorIs(t, CheckNotBefore(context.Background(), cutoffs, claims("revoked", cutoff.Unix())), ErrRevoked)
+	assert.ErrorIs(t, CheckNotBefore(context.Background(), cutoffs, jwt.MapClaims{"user_id": "revoked"}), ErrRevoked)
+	assert.NoError(t, CheckNotBefore(context.Background(), nil, claims("revoked", 1)))
+}
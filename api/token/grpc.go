@@ -0,0 +1,136 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"Auth-Service/apperrors"
+	pb "Auth-Service/genproto/users"
+	"Auth-Service/logger"
+	"Auth-Service/metrics"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// publicMethods are the UserService methods callable without an access
+// token: those that hand tokens out or carry a token of their own, and
+// those other services call on nobody's behalf.
+var publicMethods = map[string]bool{
+	pb.UserService_Register_FullMethodName:             true,
+	pb.UserService_Login_FullMethodName:                true,
+	pb.UserService_Refresh_FullMethodName:              true,
+	pb.UserService_ResetPassword_FullMethodName:        true,
+	pb.UserService_ConfirmPasswordReset_FullMethodName: true,
+	pb.UserService_CheckUsername_FullMethodName:        true,
+	pb.UserService_ResolveUsername_FullMethodName:      true,
+	pb.UserService_ConfirmEmailChange_FullMethodName:   true,
+	pb.UserService_CancelEmailChange_FullMethodName:    true,
+
+	pb.UserService_IsBlocked_FullMethodName:         true,
+	pb.UserService_BatchGetUsers_FullMethodName:     true,
+	pb.UserService_GetUserByUsername_FullMethodName: true,
+}
+
+// actingUser returns the field of req naming the user the call is made as,
+// or nil when the request names none.
+func actingUser(req interface{}) *string {
+	switch r := req.(type) {
+	case *pb.ProfileRequest:
+		return &r.ViewerId
+	case *pb.GetUsersRequest:
+		return &r.ViewerId
+	case *pb.FollowersRequest:
+		return &r.ViewerId
+	case *pb.UpdateProfileRequest:
+		return &r.Id
+	case *pb.DeleteUserRequest:
+		return &r.Id
+	case *pb.ChangePasswordRequest:
+		return &r.UserId
+	case *pb.ChangeUsernameRequest:
+		return &r.UserId
+	case *pb.RequestEmailChangeRequest:
+		return &r.UserId
+	case *pb.LogoutRequest:
+		return &r.UserId
+	case *pb.FollowRequest:
+		return &r.FollowerId
+	case *pb.FollowRequestsRequest:
+		return &r.UserId
+	case *pb.FollowRequestAction:
+		return &r.UserId
+	case *pb.RelationshipRequest:
+		return &r.UserId
+	case *pb.UserListRequest:
+		return &r.UserId
+	case *pb.ReportUserRequest:
+		return &r.ReporterId
+	case *pb.ListReportsRequest:
+		return &r.ModeratorId
+	case *pb.ClaimReportRequest:
+		return &r.ModeratorId
+	case *pb.ResolveReportRequest:
+		return &r.ModeratorId
+	case *pb.ListNotificationsRequest:
+		return &r.UserId
+	case *pb.MarkNotificationsReadRequest:
+		return &r.UserId
+	case *pb.NotificationPreferencesRequest:
+		return &r.UserId
+	case *pb.UpdateNotificationPreferencesRequest:
+		return &r.UserId
+	}
+	return nil
+}
+
+// UnaryServerInterceptor requires a valid, unrevoked access token in the
+// "authorization" metadata of every UserService call but the public ones,
+// and makes the call as the token's user: the request field naming the
+// acting user is filled in from the token, and a call naming anyone else
+// is refused.
+func UnaryServerInterceptor(cutoffs Cutoffs) grpc.UnaryServerInterceptor {
+	service := "/" + pb.UserService_ServiceDesc.ServiceName + "/"
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, service) || publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			metrics.TokenValidationFailures.WithLabelValues("missing").Inc()
+			return nil, apperrors.Unauthenticated("authorization is required", nil)
+		}
+		claims, err := ExtractAccessClaim(values[0])
+		if err != nil || claims == nil {
+			metrics.TokenValidationFailures.WithLabelValues(FailureReason(err)).Inc()
+			return nil, apperrors.Unauthenticated("invalid access token", err)
+		}
+		if err = CheckNotBefore(ctx, cutoffs, *claims); err != nil {
+			return nil, RevocationError(err)
+		}
+
+		userID, _ := (*claims)["user_id"].(string)
+		if field := actingUser(req); field != nil {
+			if *field == "" {
+				*field = userID
+			} else if *field != userID {
+				return nil, apperrors.Forbidden("cannot act on behalf of another user")
+			}
+		}
+		return handler(logger.With(ctx, zap.NewNop(), zap.String("user_id", userID)), req)
+	}
+}
+
+// RevocationError is the error to answer a failed CheckNotBefore with: a
+// revoked token is unauthenticated, while a failed lookup is unavailable.
+func RevocationError(err error) error {
+	if errors.Is(err, ErrRevoked) {
+		metrics.TokenValidationFailures.WithLabelValues(FailureReason(err)).Inc()
+		return apperrors.Unauthenticated("token has been revoked", err)
+	}
+	return apperrors.Unavailable("cannot check token revocation", err)
+}
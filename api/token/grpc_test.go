@@ -0,0 +1,85 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Auth-Service/apperrors"
+	"Auth-Service/config"
+	pb "Auth-Service/genproto/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func accessTokenFor(t *testing.T, userID string) string {
+	Configure(config.TokenConfig{AccessKey: "test-access-key", AccessTTL: time.Hour})
+	tok := &pb.Token{}
+	require.NoError(t, GeneratedAccessJWTToken(&pb.RegisterResponse{Id: userID}, tok))
+	return tok.AccessToken
+}
+
+// callAs runs req through the interceptor as method, with accessToken in
+// the metadata when it is not empty, and returns the request the handler
+// saw, if it was called.
+func callAs(cutoffs Cutoffs, method, accessToken string, req interface{}) (interface{}, error) {
+	ctx := context.Background()
+	if accessToken != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", accessToken))
+	}
+	var seen interface{}
+	_, err := UnaryServerInterceptor(cutoffs)(ctx, req, &grpc.UnaryServerInfo{FullMethod: method},
+		func(_ context.Context, req interface{}) (interface{}, error) {
+			seen = req
+			return nil, nil
+		})
+	return seen, err
+}
+
+func TestInterceptorRefusesForgedModerator(t *testing.T) {
+	tok := accessTokenFor(t, "user")
+
+	seen, err := callAs(nil, pb.UserService_ResolveReport_FullMethodName, tok,
+		&pb.ResolveReportRequest{ModeratorId: "moderator", ReportId: "report", Action: "ban"})
+
+	assert.ErrorIs(t, err, apperrors.ErrForbidden)
+	assert.Nil(t, seen)
+}
+
+func TestInterceptorActsAsTokenUser(t *testing.T) {
+	tok := accessTokenFor(t, "moderator")
+
+	seen, err := callAs(nil, pb.UserService_ClaimReport_FullMethodName, tok,
+		&pb.ClaimReportRequest{ReportId: "report"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "moderator", seen.(*pb.ClaimReportRequest).ModeratorId)
+}
+
+func TestInterceptorRequiresToken(t *testing.T) {
+	seen, err := callAs(nil, pb.UserService_UpdateProfile_FullMethodName, "",
+		&pb.UpdateProfileRequest{Id: "user"})
+
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	assert.Nil(t, seen)
+
+	// Service-to-service calls carry no user token.
+	seen, err = callAs(nil, pb.UserService_IsBlocked_FullMethodName, "",
+		&pb.IsBlockedRequest{UserId: "a", OtherId: "b"})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, seen)
+}
+
+func TestInterceptorRefusesRevokedToken(t *testing.T) {
+	tok := accessTokenFor(t, "banned")
+
+	seen, err := callAs(fixedCutoffs{"banned": time.Now().Add(time.Minute)}, pb.UserService_FollowUser_FullMethodName, tok,
+		&pb.FollowRequest{FollowerId: "banned", FollowingId: "other"})
+
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated)
+	assert.Nil(t, seen)
+}
@@ -0,0 +1,72 @@
+package token
+
+import (
+	"Auth-Service/config"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	accessKey  []byte
+	refreshKey []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+)
+
+var errNotConfigured = errors.New("token: signing keys are not configured")
+
+// Configure sets the signing keys and lifetimes used by this package. It must
+// be called once at start-up before any token is issued or parsed.
+func Configure(cfg config.TokenConfig) {
+	accessKey = []byte(cfg.AccessKey)
+	refreshKey = []byte(cfg.RefreshKey)
+	accessTTL = cfg.AccessTTL
+	refreshTTL = cfg.RefreshTTL
+}
+
+// AccessTTL returns how long a freshly issued access token stays valid.
+func AccessTTL() time.Duration {
+	return accessTTL
+}
+
+func accessKeyFunc(*jwt.Token) (interface{}, error) {
+	if len(accessKey) == 0 {
+		return nil, errNotConfigured
+	}
+	return accessKey, nil
+}
+
+func refreshKeyFunc(*jwt.Token) (interface{}, error) {
+	if len(refreshKey) == 0 {
+		return nil, errNotConfigured
+	}
+	return refreshKey, nil
+}
+
+// FailureReason classifies a token parsing error into a short, low
+// cardinality reason suitable for metrics and logs.
+func FailureReason(err error) string {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		if errors.Is(err, errNotConfigured) {
+			return "not_configured"
+		}
+		if errors.Is(err, ErrRevoked) {
+			return "revoked"
+		}
+		return "invalid"
+	}
+	switch {
+	case ve.Errors&jwt.ValidationErrorMalformed != 0:
+		return "malformed"
+	case ve.Errors&jwt.ValidationErrorExpired != 0:
+		return "expired"
+	case ve.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return "invalid_signature"
+	case ve.Errors&jwt.ValidationErrorUnverifiable != 0:
+		return "unverifiable"
+	}
+	return "invalid"
+}
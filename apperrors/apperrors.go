@@ -0,0 +1,130 @@
+// Package apperrors defines the error model shared by the repository,
+// service and transport layers. Repositories return *Error values; the HTTP
+// error renderer and the gRPC interceptor translate them into problem details
+// and gRPC status codes respectively.
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindInvalidCredentials
+	KindUnauthenticated
+	KindForbidden
+	KindValidation
+	KindUnavailable
+)
+
+var kindNames = map[Kind]string{
+	KindInternal:           "internal",
+	KindNotFound:           "not-found",
+	KindConflict:           "conflict",
+	KindInvalidCredentials: "invalid-credentials",
+	KindUnauthenticated:    "unauthenticated",
+	KindForbidden:          "forbidden",
+	KindValidation:         "validation",
+	KindUnavailable:        "unavailable",
+}
+
+// String returns a stable slug used in problem type URIs and gRPC error
+// reasons.
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// FieldViolation describes why a single request field was rejected.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+type Error struct {
+	Kind Kind
+	// Message is safe to show to clients.
+	Message string
+	Fields  []FieldViolation
+	// Err is the underlying cause. It is logged but never sent to clients.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is one of the sentinels below with the same kind,
+// so callers can write errors.Is(err, apperrors.ErrNotFound).
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Message == "" && t.Err == nil && t.Kind == e.Kind
+}
+
+var (
+	ErrNotFound           = &Error{Kind: KindNotFound}
+	ErrConflict           = &Error{Kind: KindConflict}
+	ErrInvalidCredentials = &Error{Kind: KindInvalidCredentials}
+	ErrUnauthenticated    = &Error{Kind: KindUnauthenticated}
+	ErrForbidden          = &Error{Kind: KindForbidden}
+	ErrValidation         = &Error{Kind: KindValidation}
+	ErrUnavailable        = &Error{Kind: KindUnavailable}
+)
+
+func NotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+func Conflict(message string) *Error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+func InvalidCredentials() *Error {
+	return &Error{Kind: KindInvalidCredentials, Message: "invalid username or password"}
+}
+
+func Unauthenticated(message string, cause error) *Error {
+	return &Error{Kind: KindUnauthenticated, Message: message, Err: cause}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+func Validation(fields ...FieldViolation) *Error {
+	return &Error{Kind: KindValidation, Message: "request validation failed", Fields: fields}
+}
+
+func Unavailable(message string, cause error) *Error {
+	return &Error{Kind: KindUnavailable, Message: message, Err: cause}
+}
+
+// Internal wraps an unexpected error. Its details stay in the logs.
+func Internal(cause error) *Error {
+	return &Error{Kind: KindInternal, Message: "internal server error", Err: cause}
+}
+
+// From returns err as an *Error, treating anything unknown as internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
+
+// KindOf returns the kind of err, or KindInternal for foreign errors.
+func KindOf(err error) Kind {
+	return From(err).Kind
+}
@@ -0,0 +1,67 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+const errorDomain = "auth-service"
+
+var grpcCodes = map[Kind]codes.Code{
+	KindInternal:           codes.Internal,
+	KindNotFound:           codes.NotFound,
+	KindConflict:           codes.AlreadyExists,
+	KindInvalidCredentials: codes.Unauthenticated,
+	KindUnauthenticated:    codes.Unauthenticated,
+	KindForbidden:          codes.PermissionDenied,
+	KindValidation:         codes.InvalidArgument,
+	KindUnavailable:        codes.Unavailable,
+}
+
+// GRPCStatus converts err into a status carrying an ErrorInfo detail with the
+// error kind and, for validation errors, a BadRequest detail listing every
+// rejected field.
+func GRPCStatus(err error) *status.Status {
+	if _, ok := status.FromError(err); ok {
+		return status.Convert(err)
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.New(codes.Canceled, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.New(codes.DeadlineExceeded, err.Error())
+	}
+
+	e := From(err)
+	st := status.New(grpcCodes[e.Kind], e.Message)
+
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: e.Kind.String(), Domain: errorDomain}}
+	if len(e.Fields) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(e.Fields))
+		for i, f := range e.Fields {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Description}
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+	if withDetails, detailsErr := st.WithDetails(details...); detailsErr == nil {
+		return withDetails
+	}
+	return st
+}
+
+// UnaryServerInterceptor maps errors returned by handlers to gRPC statuses.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, GRPCStatus(err).Err()
+		}
+		return resp, nil
+	}
+}
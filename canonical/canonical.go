@@ -0,0 +1,60 @@
+// Package canonical computes the forms of usernames and emails used for
+// uniqueness and lookups. Display forms keep the user's casing; canonical
+// forms are Unicode NFKC-normalized and case-folded so that "Alice" and
+// "alice", or "Bob@Mail.com" and "bob@mail.com", are the same account.
+package canonical
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// fold is full Unicode case folding ("ß" and "SS" fold alike). PostgreSQL's
+// lower() does not do this, so canonical forms are only ever computed here:
+// by the queries that store identities and, for users created before
+// migration 000003, by cmd/canonicalize.
+var fold = cases.Fold()
+
+// Display returns s as it should be stored for display: trimmed and in NFC.
+func Display(s string) string {
+	return norm.NFC.String(strings.TrimSpace(s))
+}
+
+// Username returns the canonical form of a username.
+func Username(s string) string {
+	return fold.String(norm.NFKC.String(strings.TrimSpace(s)))
+}
+
+// Email returns the canonical form of an email address. The local part is
+// folded as well: no mainstream provider treats it as case-sensitive.
+func Email(s string) string {
+	return fold.String(norm.NFKC.String(strings.TrimSpace(s)))
+}
+
+// UserIDs returns the canonical forms of user ids, in order and without
+// duplicates. A UUID may be written in upper case, in braces or as a URN;
+// its canonical form is the lower-case, hyphenated one Postgres returns.
+// Anything that is not a UUID is kept as it is.
+func UserIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if parsed, err := uuid.Parse(id); err == nil {
+			id = parsed.String()
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// IsEmail reports whether a login identifier is an email address rather
+// than a username. Usernames cannot contain "@".
+func IsEmail(identifier string) bool {
+	return strings.Contains(identifier, "@")
+}
@@ -0,0 +1,26 @@
+package canonical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalForms(t *testing.T) {
+	assert.Equal(t, Username("alice"), Username(" Alice "))
+	assert.Equal(t, Username("alice"), Username("ＡＬＩＣＥ"))
+	assert.Equal(t, Email("bob@mail.com"), Email("Bob@Mail.COM"))
+	assert.Equal(t, Username("strasse"), Username("Straße"))
+	assert.Equal(t, "Ålesund", Display("Ålesund "))
+}
+
+func TestUserIDs(t *testing.T) {
+	const id = "0b6e5ab4-3f3c-4c1e-9c47-2f8f0c7d9a10"
+	assert.Equal(t, []string{id, "not-a-uuid"}, UserIDs([]string{
+		"0B6E5AB4-3F3C-4C1E-9C47-2F8F0C7D9A10",
+		"{" + id + "}",
+		"urn:uuid:" + id,
+		"not-a-uuid",
+		id,
+	}))
+}
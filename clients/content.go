@@ -0,0 +1,24 @@
+package clients
+
+import (
+	"Auth-Service/genproto/content"
+	"Auth-Service/logger"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// NewContentClient connects to the content service. Outgoing calls carry the
+// caller's trace context so both services show up in one trace.
+func NewContentClient(addr string) (content.ContentClient, *grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithUnaryInterceptor(logger.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content.NewContentClient(conn), conn, nil
+}
@@ -0,0 +1,32 @@
+// Command canonicalize fills in the canonical usernames and emails of all
+// users with package canonical and finishes the schema change migration
+// 000003 starts. Run it right after that migration; when users collide it
+// lists every collision and changes nothing. Running it again is harmless.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"Auth-Service/config"
+	"Auth-Service/storage/postgres"
+)
+
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := postgres.ConnectionDb(cfg.Postgres)
+	if err != nil {
+		log.Fatalf("failed to open postgres: %v", err)
+	}
+	defer db.Close()
+
+	fixed, err := postgres.NewUserRepository(db, cfg).RecanonicalizeIdentities(context.Background())
+	if err != nil {
+		log.Fatalf("failed to recanonicalize identities: %v", err)
+	}
+	log.Printf("corrected %d users", fixed)
+}
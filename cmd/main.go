@@ -1,22 +1,38 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
+
 	router "Auth-Service/api"
 	"Auth-Service/api/handlers"
+	"Auth-Service/api/token"
+	"Auth-Service/clients"
 	"Auth-Service/cmd/server"
 	"Auth-Service/config"
+	"Auth-Service/genproto/content"
+	"Auth-Service/health"
 	l "Auth-Service/logger"
+	"Auth-Service/mailer"
+	"Auth-Service/metrics"
+	"Auth-Service/password"
+	"Auth-Service/reserved"
 	"Auth-Service/storage/postgres"
+	"Auth-Service/storage/redis"
+	"Auth-Service/tracing"
 
 	"go.uber.org/zap"
 )
 
 var logger *zap.Logger
 
-func initLog() {
-	log, err := l.NewLogger()
+func initLog(cfg config.LogConfig) {
+	log, err := l.NewLogger(cfg)
 	if err != nil {
 		panic(err)
 	}
@@ -24,28 +40,128 @@ func initLog() {
 }
 
 func main() {
-	initLog()
-	db, err := postgres.ConnectionDb()
+	cfg, err := config.Load(os.Args[1:])
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
+	token.Configure(cfg.Token)
 
-	cfg := config.Load()
+	initLog(cfg.Log)
+	defer logger.Sync()
 
-	router := router.NewRouter(handlers.NewHandler(postgres.NewUserRepository(db), logger))
+	if err := password.Configure(cfg.Password); err != nil {
+		logger.Fatal("failed to load password policy", zap.Error(err))
+	}
+	if err := reserved.Configure(cfg.Username.ReservedFile); err != nil {
+		logger.Fatal("failed to load reserved usernames", zap.Error(err))
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	templates, err := mailer.LoadTemplates(cfg.Mail.TemplatesDir, cfg.Mail.DefaultLocale)
+	if err != nil {
+		logger.Fatal("failed to load mail templates", zap.Error(err))
+	}
+	mail, err := mailer.New(cfg.Mail, cfg.SMTP, logger)
+	if err != nil {
+		logger.Fatal("failed to set up mailer", zap.Error(err))
+	}
 
-	go func() {
-		defer wg.Done()
-		err := router.Run(cfg.HTTPPort)
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Fatal("failed to set up tracing", zap.Error(err))
+	}
+
+	db, err := postgres.ConnectionDb(cfg.Postgres)
+	if err != nil {
+		logger.Fatal("failed to open postgres", zap.Error(err))
+	}
+	rdb := redis.ConnectionRedis(cfg.Redis)
+	metrics.RegisterStores(db, rdb)
+
+	var contentClient content.ContentClient
+	if cfg.ContentServiceAddr != "" {
+		client, conn, err := clients.NewContentClient(cfg.ContentServiceAddr)
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal("failed to create content service client", zap.Error(err))
 		}
+		defer conn.Close()
+		contentClient = client
+	}
+
+	userRepo := postgres.NewUserRepository(db, cfg)
+	cutoffs := redis.NewTokenCutoffs(rdb, userRepo, cfg.Token.CutoffCacheTTL)
+	userRepo.TokenCutoffs = cutoffs
+	summaries := redis.NewUserSummaries(rdb, userRepo, cfg.UserCacheTTL)
+	userRepo.UserCache = summaries
+	checker := health.NewChecker(db, rdb)
+	router := router.NewRouter(handlers.NewHandler(userRepo, summaries, cutoffs, checker, logger))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var background sync.WaitGroup
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		mailer.NewWorker(userRepo, mail, templates, cfg.Mail, logger).Run(ctx)
 	}()
+	go func() {
+		defer background.Done()
+		reconcileFollowCounts(ctx, userRepo, cfg.FollowCountsReconcileInterval)
+	}()
+
+	runErr := server.Run(ctx, cfg, router, server.NewGRPCServer(userRepo, summaries, cutoffs, contentClient, checker, logger), checker, logger)
+	if runErr != nil {
+		logger.Error("server stopped with error", zap.Error(runErr))
+	}
+	// The servers may also stop on their own; either way the background jobs
+	// have to finish before the database goes away.
+	stop()
+	background.Wait()
+
+	// Servers are drained at this point, so nothing uses the stores any more.
+	if err := db.Close(); err != nil {
+		logger.Error("failed to close postgres", zap.Error(err))
+	}
+	if err := rdb.Close(); err != nil {
+		logger.Error("failed to close redis", zap.Error(err))
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("failed to flush traces", zap.Error(err))
+	}
+	cancel()
+	logger.Info("shutdown complete")
 
-	server.ServerRun(postgres.NewUserRepository(db), &cfg)
-	wg.Wait()
+	if runErr != nil {
+		logger.Sync()
+		os.Exit(1)
+	}
+}
+
+// reconcileFollowCounts corrects drifted follow counts every interval until
+// ctx is cancelled. A zero interval disables it.
+func reconcileFollowCounts(ctx context.Context, repo *postgres.UserRepository, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		fixed, err := repo.ReconcileFollowCounts(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Warn("failed to reconcile follow counts", zap.Error(err))
+			}
+			continue
+		}
+		if fixed > 0 {
+			logger.Info("corrected follow counts", zap.Int64("users", fixed))
+		}
+	}
 }
@@ -1,27 +1,119 @@
 package server
 
 import (
+	"Auth-Service/api/token"
+	"Auth-Service/apperrors"
 	"Auth-Service/config"
+	"Auth-Service/genproto/content"
 	"Auth-Service/genproto/users"
+	"Auth-Service/health"
+	"Auth-Service/logger"
+	"Auth-Service/metrics"
 	"Auth-Service/service"
 	"Auth-Service/storage/postgres"
-	"log"
+	"Auth-Service/storage/redis"
+	"Auth-Service/validation"
+	"context"
+	"errors"
 	"net"
+	"net/http"
+	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
-func ServerRun(userRepo *postgres.UserRepository, cfg *config.Config) {
-	listener, err := net.Listen("tcp", ":50051")
+const healthCheckInterval = 10 * time.Second
+
+func NewGRPCServer(userRepo *postgres.UserRepository, summaries *redis.UserSummaries, cutoffs token.Cutoffs, contentClient content.ContentClient, checker *health.Checker, log *zap.Logger) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logger.UnaryServerInterceptor(log),
+			metrics.UnaryServerInterceptor(),
+			apperrors.UnaryServerInterceptor(),
+			token.UnaryServerInterceptor(cutoffs),
+			validation.UnaryServerInterceptor(),
+		),
+	)
+	users.RegisterUserServiceServer(s, service.NewUserService(userRepo, summaries, contentClient))
+	healthpb.RegisterHealthServer(s, checker.GRPCServer())
+	return s
+}
+
+// Run serves HTTP and gRPC until ctx is cancelled or either server fails,
+// then marks the service not ready and drains both. In-flight requests get
+// cfg.ShutdownTimeout to finish before the remaining connections are closed
+// forcefully.
+func Run(ctx context.Context, cfg *config.Config, handler http.Handler, grpcServer *grpc.Server, checker *health.Checker, logger *zap.Logger) error {
+	httpServer := &http.Server{
+		Addr:    cfg.HTTPPort,
+		Handler: handler,
+	}
+
+	listener, err := net.Listen("tcp", cfg.GRPCPort)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	s := grpc.NewServer()
-	users.RegisterUserServiceServer(s, service.NewUserService(userRepo))
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	go checker.Watch(watchCtx, healthCheckInterval, logger)
 
-	log.Printf("Server is running on %v", listener.Addr())
-	if err := s.Serve(listener); err != nil {
-		log.Fatal(err)
+	errCh := make(chan error, 2)
+	go func() {
+		logger.Info("HTTP server is running", zap.String("addr", cfg.HTTPPort))
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+	go func() {
+		logger.Info("gRPC server is running", zap.String("addr", listener.Addr().String()))
+		if err := grpcServer.Serve(listener); err != nil {
+			errCh <- err
+		}
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		logger.Info("shutdown signal received, draining servers")
+	case runErr = <-errCh:
+		logger.Error("server failed, shutting down", zap.Error(runErr))
 	}
+
+	stopWatch()
+	checker.Shutdown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	return errors.Join(runErr, shutdown(shutdownCtx, httpServer, grpcServer, logger))
+}
+
+func shutdown(ctx context.Context, httpServer *http.Server, grpcServer *grpc.Server, logger *zap.Logger) error {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	err := httpServer.Shutdown(ctx)
+	if err != nil {
+		logger.Warn("HTTP server did not drain in time", zap.Error(err))
+		err = errors.Join(err, httpServer.Close())
+	}
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logger.Warn("gRPC server did not drain in time, closing connections")
+		grpcServer.Stop()
+		<-stopped
+	}
+
+	logger.Info("servers stopped")
+	return err
 }
@@ -1,52 +1,698 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cast"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	HTTPPort string
+	HTTPPort        string
+	GRPCPort        string
+	ShutdownTimeout time.Duration
 
-	PostgresHost     string
-	PostgresPort     int
-	PostgresUser     string
-	PostgresPassword string
-	PostgresDatabase string
+	Postgres PostgresConfig
+	Redis    RedisConfig
+	SMTP     SMTPConfig
+	Mail     MailConfig
+	Token    TokenConfig
+	Tracing  TracingConfig
+	Log      LogConfig
+	Password PasswordConfig
+	Username UsernameConfig
+
+	// FollowCountsReconcileInterval is how often the stored follow counts
+	// are checked against the followers table; 0 disables the job.
+	FollowCountsReconcileInterval time.Duration
+
+	// EmailChangeTTL is how long the links sent for an email change stay
+	// valid.
+	EmailChangeTTL time.Duration
+
+	// UserCacheTTL is how long user summaries are cached in Redis.
+	UserCacheTTL time.Duration
+
+	// PublicURL is the externally reachable base URL used in links sent to
+	// users, such as password reset emails.
+	PublicURL string
+
+	ContentServiceAddr string
 
 	DefaultOffset string
 	DefaultLimit  string
+}
+
+type PostgresConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+}
+
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type MailConfig struct {
+	// Driver selects how mail leaves the service: smtp, file or log.
+	Driver string
+	// Dir is where the file driver writes .eml files.
+	Dir string
+	// TemplatesDir replaces the built-in templates when set.
+	TemplatesDir string
+	// DefaultLocale is used when a template has no variant for the
+	// recipient's locale.
+	DefaultLocale string
+	// PollInterval is how often the outbox worker looks for due messages.
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is how many deliveries are tried before a message is
+	// given up on.
+	MaxAttempts int
+	// RetryBase and RetryMax bound the exponential backoff between attempts.
+	RetryBase time.Duration
+	RetryMax  time.Duration
+}
+
+type TokenConfig struct {
+	AccessKey  string
+	RefreshKey string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+
+	// CutoffCacheTTL is how long a user's tokens-not-before time is kept in
+	// Redis before it is read from Postgres again.
+	CutoffCacheTTL time.Duration
+}
+
+type TracingConfig struct {
+	Exporter     string
+	OTLPEndpoint string
+	OTLPInsecure bool
+	SampleRatio  float64
+	ServiceName  string
+}
+
+type LogConfig struct {
+	Level          string
+	Format         string
+	File           string
+	MaxSizeMB      int
+	MaxAgeDays     int
+	MaxBackups     int
+	RotateInterval time.Duration
+}
+
+type PasswordConfig struct {
+	MinLength        int
+	MaxLength        int
+	RequireUpper     bool
+	RequireLower     bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	DisallowIdentity bool
+	// BreachedList is a SHA-1 hash list file or a directory of k-anonymity
+	// range files. Empty means the small built-in list.
+	BreachedList  string
+	BreachedCheck bool
+	ResetTTL      time.Duration
+}
+
+type UsernameConfig struct {
+	// ReservedFile extends the built-in reserved username list.
+	ReservedFile string
+	// ChangeCooldown is the minimum time between two username changes.
+	ChangeCooldown time.Duration
+	// RedirectGrace is how long an old username keeps resolving to its
+	// previous owner after a change.
+	RedirectGrace time.Duration
+	// HoldPeriod is how long an old username stays unavailable to others.
+	HoldPeriod time.Duration
+}
+
+// field describes one configuration key. The key is the environment variable
+// name; the file key is the same name lower-cased with "_" separating nesting
+// levels (POSTGRES_HOST <-> postgres.host) and the flag is -postgres-host.
+// Secret keys can also be given as KEY_FILE pointing at a file with the value.
+type field struct {
+	key    string
+	def    string
+	secret bool
+	usage  string
+	set    func(c *Config, v string) error
+}
+
+var fields = []field{
+	{key: "HTTP_PORT", def: ":8081", usage: "HTTP listen address", set: func(c *Config, v string) error {
+		c.HTTPPort = v
+		return nil
+	}},
+	{key: "GRPC_PORT", def: ":50051", usage: "gRPC listen address", set: func(c *Config, v string) error {
+		c.GRPCPort = v
+		return nil
+	}},
+	{key: "SHUTDOWN_TIMEOUT", def: "15s", usage: "time allowed for in-flight requests on shutdown", set: func(c *Config, v string) (err error) {
+		c.ShutdownTimeout, err = cast.ToDurationE(v)
+		return err
+	}},
+
+	{key: "POSTGRES_HOST", def: "localhost", usage: "Postgres host", set: func(c *Config, v string) error {
+		c.Postgres.Host = v
+		return nil
+	}},
+	{key: "POSTGRES_PORT", def: "5432", usage: "Postgres port", set: func(c *Config, v string) (err error) {
+		c.Postgres.Port, err = cast.ToIntE(v)
+		return err
+	}},
+	{key: "POSTGRES_USER", def: "macbookpro", usage: "Postgres user", set: func(c *Config, v string) error {
+		c.Postgres.User = v
+		return nil
+	}},
+	{key: "POSTGRES_PASSWORD", secret: true, usage: "Postgres password", set: func(c *Config, v string) error {
+		c.Postgres.Password = v
+		return nil
+	}},
+	{key: "POSTGRES_DATABASE", def: "content", usage: "Postgres database name", set: func(c *Config, v string) error {
+		c.Postgres.Database = v
+		return nil
+	}},
+
+	{key: "REDIS_ADDR", def: "localhost:6379", usage: "Redis address", set: func(c *Config, v string) error {
+		c.Redis.Addr = v
+		return nil
+	}},
+	{key: "REDIS_PASSWORD", secret: true, usage: "Redis password", set: func(c *Config, v string) error {
+		c.Redis.Password = v
+		return nil
+	}},
+	{key: "REDIS_DB", def: "0", usage: "Redis database number", set: func(c *Config, v string) (err error) {
+		c.Redis.DB, err = cast.ToIntE(v)
+		return err
+	}},
+
+	{key: "SMTP_HOST", def: "smtp.gmail.com", usage: "SMTP host", set: func(c *Config, v string) error {
+		c.SMTP.Host = v
+		return nil
+	}},
+	{key: "SMTP_PORT", def: "587", usage: "SMTP port", set: func(c *Config, v string) (err error) {
+		c.SMTP.Port, err = cast.ToIntE(v)
+		return err
+	}},
+	{key: "SMTP_USERNAME", usage: "SMTP user name", set: func(c *Config, v string) error {
+		c.SMTP.Username = v
+		return nil
+	}},
+	{key: "SMTP_PASSWORD", secret: true, usage: "SMTP password", set: func(c *Config, v string) error {
+		c.SMTP.Password = v
+		return nil
+	}},
+	{key: "SMTP_FROM", usage: "sender address for outgoing mail", set: func(c *Config, v string) error {
+		c.SMTP.From = v
+		return nil
+	}},
+
+	{key: "MAIL_DRIVER", def: "smtp", usage: "mail transport: smtp, file or log", set: func(c *Config, v string) error {
+		c.Mail.Driver = v
+		return nil
+	}},
+	{key: "MAIL_DIR", def: "mail", usage: "output directory of the file mail driver", set: func(c *Config, v string) error {
+		c.Mail.Dir = v
+		return nil
+	}},
+	{key: "MAIL_TEMPLATES_DIR", usage: "directory with mail templates; empty uses the built-in ones", set: func(c *Config, v string) error {
+		c.Mail.TemplatesDir = v
+		return nil
+	}},
+	{key: "MAIL_DEFAULT_LOCALE", def: "en", usage: "locale used when a template has no variant for the recipient", set: func(c *Config, v string) error {
+		c.Mail.DefaultLocale = v
+		return nil
+	}},
+	{key: "MAIL_POLL_INTERVAL", def: "5s", usage: "how often the outbox worker looks for due mail", set: func(c *Config, v string) (err error) {
+		c.Mail.PollInterval, err = cast.ToDurationE(v)
+		return err
+	}},
+	{key: "MAIL_BATCH_SIZE", def: "20", usage: "messages claimed from the outbox at a time", set: func(c *Config, v string) (err error) {
+		c.Mail.BatchSize, err = cast.ToIntE(v)
+		return err
+	}},
+	{key: "MAIL_MAX_ATTEMPTS", def: "8", usage: "delivery attempts before a message is marked failed", set: func(c *Config, v string) (err error) {
+		c.Mail.MaxAttempts, err = cast.ToIntE(v)
+		return err
+	}},
+	{key: "MAIL_RETRY_BASE", def: "30s", usage: "delay before the first retry; doubled on each further attempt", set: func(c *Config, v string) (err error) {
+		c.Mail.RetryBase, err = cast.ToDurationE(v)
+		return err
+	}},
+	{key: "MAIL_RETRY_MAX", def: "1h", usage: "upper bound of the delay between retries", set: func(c *Config, v string) (err error) {
+		c.Mail.RetryMax, err = cast.ToDurationE(v)
+		return err
+	}},
+
+	{key: "TOKEN_ACCESS_KEY", secret: true, usage: "HMAC key for access tokens", set: func(c *Config, v string) error {
+		c.Token.AccessKey = v
+		return nil
+	}},
+	{key: "TOKEN_REFRESH_KEY", secret: true, usage: "HMAC key for refresh tokens", set: func(c *Config, v string) error {
+		c.Token.RefreshKey = v
+		return nil
+	}},
+	{key: "TOKEN_ACCESS_TTL", def: "30m", usage: "access token lifetime", set: func(c *Config, v string) (err error) {
+		c.Token.AccessTTL, err = cast.ToDurationE(v)
+		return err
+	}},
+	{key: "TOKEN_REFRESH_TTL", def: "24h", usage: "refresh token lifetime", set: func(c *Config, v string) (err error) {
+		c.Token.RefreshTTL, err = cast.ToDurationE(v)
+		return err
+	}},
+	{key: "TOKEN_CUTOFF_CACHE_TTL", def: "10m", usage: "how long revocation cut-offs are cached in Redis", set: func(c *Config, v string) (err error) {
+		c.Token.CutoffCacheTTL, err = cast.ToDurationE(v)
+		return err
+	}},
+
+	{key: "TRACING_EXPORTER", def: "none", usage: "span exporter: none, otlp or stdout", set: func(c *Config, v string) error {
+		c.Tracing.Exporter = v
+		return nil
+	}},
+	{key: "TRACING_OTLP_ENDPOINT", def: "localhost:4317", usage: "OTLP gRPC collector address", set: func(c *Config, v string) error {
+		c.Tracing.OTLPEndpoint = v
+		return nil
+	}},
+	{key: "TRACING_OTLP_INSECURE", def: "true", usage: "disable TLS towards the OTLP collector", set: func(c *Config, v string) (err error) {
+		c.Tracing.OTLPInsecure, err = cast.ToBoolE(v)
+		return err
+	}},
+	{key: "TRACING_SAMPLE_RATIO", def: "1", usage: "fraction of new traces to sample", set: func(c *Config, v string) (err error) {
+		c.Tracing.SampleRatio, err = cast.ToFloat64E(v)
+		return err
+	}},
+	{key: "TRACING_SERVICE_NAME", def: "auth-service", usage: "service.name resource attribute", set: func(c *Config, v string) error {
+		c.Tracing.ServiceName = v
+		return nil
+	}},
+
+	{key: "LOG_LEVEL", def: "info", usage: "minimum log level: debug, info, warn or error", set: func(c *Config, v string) error {
+		c.Log.Level = v
+		return nil
+	}},
+	{key: "LOG_FORMAT", def: "json", usage: "log encoding: json or console", set: func(c *Config, v string) error {
+		c.Log.Format = v
+		return nil
+	}},
+	{key: "LOG_FILE", def: "app.log", usage: "log file path, empty to log to stdout only", set: func(c *Config, v string) error {
+		c.Log.File = v
+		return nil
+	}},
+	{key: "LOG_MAX_SIZE_MB", def: "100", usage: "rotate the log file once it reaches this size", set: func(c *Config, v string) (err error) {
+		c.Log.MaxSizeMB, err = cast.ToIntE(v)
+		return err
+	}},
+	{key: "LOG_MAX_AGE_DAYS", def: "28", usage: "delete rotated log files older than this", set: func(c *Config, v string) (err error) {
+		c.Log.MaxAgeDays, err = cast.ToIntE(v)
+		return err
+	}},
+	{key: "LOG_MAX_BACKUPS", def: "7", usage: "number of rotated log files to keep", set: func(c *Config, v string) (err error) {
+		c.Log.MaxBackups, err = cast.ToIntE(v)
+		return err
+	}},
+	{key: "LOG_ROTATE_INTERVAL", def: "24h", usage: "rotate the log file this often regardless of size, 0 to disable", set: func(c *Config, v string) (err error) {
+		c.Log.RotateInterval, err = cast.ToDurationE(v)
+		return err
+	}},
+
+	{key: "PASSWORD_MIN_LENGTH", def: "8", usage: "minimum password length", set: func(c *Config, v string) (err error) {
+		c.Password.MinLength, err = cast.ToIntE(v)
+		return err
+	}},
+	{key: "PASSWORD_MAX_LENGTH", def: "128", usage: "maximum password length", set: func(c *Config, v string) (err error) {
+		c.Password.MaxLength, err = cast.ToIntE(v)
+		return err
+	}},
+	{key: "PASSWORD_REQUIRE_UPPER", def: "true", usage: "require an uppercase letter in passwords", set: func(c *Config, v string) (err error) {
+		c.Password.RequireUpper, err = cast.ToBoolE(v)
+		return err
+	}},
+	{key: "PASSWORD_REQUIRE_LOWER", def: "true", usage: "require a lowercase letter in passwords", set: func(c *Config, v string) (err error) {
+		c.Password.RequireLower, err = cast.ToBoolE(v)
+		return err
+	}},
+	{key: "PASSWORD_REQUIRE_DIGIT", def: "true", usage: "require a digit in passwords", set: func(c *Config, v string) (err error) {
+		c.Password.RequireDigit, err = cast.ToBoolE(v)
+		return err
+	}},
+	{key: "PASSWORD_REQUIRE_SYMBOL", def: "false", usage: "require a symbol in passwords", set: func(c *Config, v string) (err error) {
+		c.Password.RequireSymbol, err = cast.ToBoolE(v)
+		return err
+	}},
+	{key: "PASSWORD_DISALLOW_IDENTITY", def: "true", usage: "reject passwords containing the username or email", set: func(c *Config, v string) (err error) {
+		c.Password.DisallowIdentity, err = cast.ToBoolE(v)
+		return err
+	}},
+	{key: "PASSWORD_BREACHED_LIST", usage: "SHA-1 breached password list file or range directory; empty uses the built-in list", set: func(c *Config, v string) error {
+		c.Password.BreachedList = v
+		return nil
+	}},
+	{key: "PASSWORD_BREACHED_CHECK", def: "true", usage: "reject passwords found in the breached list", set: func(c *Config, v string) (err error) {
+		c.Password.BreachedCheck, err = cast.ToBoolE(v)
+		return err
+	}},
+	{key: "PASSWORD_RESET_TTL", def: "1h", usage: "lifetime of password reset links", set: func(c *Config, v string) (err error) {
+		c.Password.ResetTTL, err = cast.ToDurationE(v)
+		return err
+	}},
+
+	{key: "USERNAME_RESERVED_FILE", usage: "file with extra reserved usernames, one per line", set: func(c *Config, v string) error {
+		c.Username.ReservedFile = v
+		return nil
+	}},
+
+	{key: "USERNAME_CHANGE_COOLDOWN", def: "720h", usage: "minimum time between username changes", set: func(c *Config, v string) (err error) {
+		c.Username.ChangeCooldown, err = cast.ToDurationE(v)
+		return err
+	}},
+	{key: "USERNAME_REDIRECT_GRACE", def: "336h", usage: "how long an old username resolves to its previous owner", set: func(c *Config, v string) (err error) {
+		c.Username.RedirectGrace, err = cast.ToDurationE(v)
+		return err
+	}},
+	{key: "USERNAME_HOLD_PERIOD", def: "720h", usage: "how long an old username cannot be claimed by someone else", set: func(c *Config, v string) (err error) {
+		c.Username.HoldPeriod, err = cast.ToDurationE(v)
+		return err
+	}},
+
+	{key: "FOLLOW_COUNTS_RECONCILE_INTERVAL", def: "1h", usage: "how often follow counts are recomputed, 0 to disable", set: func(c *Config, v string) (err error) {
+		c.FollowCountsReconcileInterval, err = cast.ToDurationE(v)
+		return err
+	}},
+
+	{key: "EMAIL_CHANGE_TTL", def: "24h", usage: "lifetime of email change confirmation links", set: func(c *Config, v string) (err error) {
+		c.EmailChangeTTL, err = cast.ToDurationE(v)
+		return err
+	}},
+	{key: "USER_CACHE_TTL", def: "5m", usage: "how long user summaries are cached in Redis", set: func(c *Config, v string) (err error) {
+		c.UserCacheTTL, err = cast.ToDurationE(v)
+		return err
+	}},
+
+	{key: "PUBLIC_URL", def: "http://localhost:8081", usage: "external base URL used in links sent to users", set: func(c *Config, v string) error {
+		c.PublicURL = strings.TrimSuffix(v, "/")
+		return nil
+	}},
+
+	{key: "CONTENT_SERVICE_ADDR", usage: "gRPC address of the content service", set: func(c *Config, v string) error {
+		c.ContentServiceAddr = v
+		return nil
+	}},
+
+	{key: "DEFAULT_OFFSET", def: "0", usage: "default list offset", set: func(c *Config, v string) error {
+		c.DefaultOffset = v
+		return nil
+	}},
+	{key: "DEFAULT_LIMIT", def: "10", usage: "default list limit", set: func(c *Config, v string) error {
+		c.DefaultLimit = v
+		return nil
+	}},
+}
+
+// Load builds the configuration from, in increasing precedence: built-in
+// defaults, the file given by -config or CONFIG_FILE (YAML or TOML), the
+// environment (including a .env file) and command line flags.
+func Load(args []string) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("config: reading .env: %w", err)
+	}
+
+	fs := flag.NewFlagSet("auth-service", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	flagValues := make(map[string]*string, len(fields)*2)
+	for _, f := range fields {
+		flagValues[f.key] = fs.String(flagName(f.key), "", f.usage)
+		if f.secret {
+			flagValues[f.key+"_FILE"] = fs.String(flagName(f.key+"_FILE"), "", "file containing the "+f.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		values[f.key] = f.def
+	}
+
+	if *configFile != "" {
+		fileValues, err := readFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := apply(values, fileValues); err != nil {
+			return nil, err
+		}
+	}
+
+	envValues := make(map[string]string)
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(f.key); ok {
+			envValues[f.key] = v
+		}
+		if v, ok := os.LookupEnv(f.key + "_FILE"); ok && f.secret {
+			envValues[f.key+"_FILE"] = v
+		}
+	}
+	if err := apply(values, envValues); err != nil {
+		return nil, err
+	}
+
+	cliValues := make(map[string]string)
+	fs.Visit(func(fl *flag.Flag) {
+		for key, v := range flagValues {
+			if flagName(key) == fl.Name {
+				cliValues[key] = *v
+			}
+		}
+	})
+	if err := apply(values, cliValues); err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	for _, f := range fields {
+		if err := f.set(cfg, values[f.key]); err != nil {
+			return nil, fmt.Errorf("config: invalid %s: %w", f.key, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate reports every missing secret or out-of-range value at once so a
+// misconfigured deployment fails on start-up instead of on first use.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Postgres.Password == "" {
+		errs = append(errs, errors.New("POSTGRES_PASSWORD (or POSTGRES_PASSWORD_FILE) is required"))
+	}
+	if c.Token.AccessKey == "" {
+		errs = append(errs, errors.New("TOKEN_ACCESS_KEY (or TOKEN_ACCESS_KEY_FILE) is required"))
+	}
+	if c.Token.RefreshKey == "" {
+		errs = append(errs, errors.New("TOKEN_REFRESH_KEY (or TOKEN_REFRESH_KEY_FILE) is required"))
+	}
+	if c.HTTPPort == "" {
+		errs = append(errs, errors.New("HTTP_PORT must not be empty"))
+	}
+	if c.GRPCPort == "" {
+		errs = append(errs, errors.New("GRPC_PORT must not be empty"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if c.Postgres.Port <= 0 || c.Postgres.Port > 65535 {
+		errs = append(errs, fmt.Errorf("POSTGRES_PORT %d is out of range", c.Postgres.Port))
+	}
+	if c.SMTP.Port <= 0 || c.SMTP.Port > 65535 {
+		errs = append(errs, fmt.Errorf("SMTP_PORT %d is out of range", c.SMTP.Port))
+	}
+	if c.SMTP.Username != "" && c.SMTP.Password == "" {
+		errs = append(errs, errors.New("SMTP_PASSWORD (or SMTP_PASSWORD_FILE) is required when SMTP_USERNAME is set"))
+	}
+	switch c.Mail.Driver {
+	case "smtp", "log":
+	case "file":
+		if c.Mail.Dir == "" {
+			errs = append(errs, errors.New("MAIL_DIR is required for the file mail driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("MAIL_DRIVER %q must be one of smtp, file, log", c.Mail.Driver))
+	}
+	if c.Mail.PollInterval <= 0 || c.Mail.BatchSize < 1 || c.Mail.MaxAttempts < 1 {
+		errs = append(errs, errors.New("MAIL_POLL_INTERVAL, MAIL_BATCH_SIZE and MAIL_MAX_ATTEMPTS must be positive"))
+	}
+	if c.Mail.RetryBase <= 0 || c.Mail.RetryMax < c.Mail.RetryBase {
+		errs = append(errs, errors.New("MAIL_RETRY_BASE must be positive and not exceed MAIL_RETRY_MAX"))
+	}
+	if c.Token.AccessKey != "" && c.Token.AccessKey == c.Token.RefreshKey {
+		errs = append(errs, errors.New("TOKEN_ACCESS_KEY and TOKEN_REFRESH_KEY must differ"))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER %q must be one of none, otlp, stdout", c.Tracing.Exporter))
+	}
+	if _, err := zapcore.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: %w", err))
+	}
+	if c.Log.Format != "json" && c.Log.Format != "console" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT %q must be json or console", c.Log.Format))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("TRACING_SAMPLE_RATIO must be between 0 and 1"))
+	}
+	if c.Token.AccessTTL <= 0 {
+		errs = append(errs, errors.New("TOKEN_ACCESS_TTL must be positive"))
+	}
+	if c.Token.RefreshTTL <= c.Token.AccessTTL {
+		errs = append(errs, errors.New("TOKEN_REFRESH_TTL must be longer than TOKEN_ACCESS_TTL"))
+	}
+	if c.Token.CutoffCacheTTL <= 0 {
+		errs = append(errs, errors.New("TOKEN_CUTOFF_CACHE_TTL must be positive"))
+	}
+	if c.Password.MinLength < 1 {
+		errs = append(errs, errors.New("PASSWORD_MIN_LENGTH must be positive"))
+	}
+	if c.Password.MaxLength < c.Password.MinLength {
+		errs = append(errs, errors.New("PASSWORD_MAX_LENGTH must not be less than PASSWORD_MIN_LENGTH"))
+	}
+	if c.Username.ChangeCooldown < 0 || c.Username.RedirectGrace < 0 {
+		errs = append(errs, errors.New("USERNAME_CHANGE_COOLDOWN and USERNAME_REDIRECT_GRACE must not be negative"))
+	}
+	if c.Username.HoldPeriod < c.Username.RedirectGrace {
+		errs = append(errs, errors.New("USERNAME_HOLD_PERIOD must not be shorter than USERNAME_REDIRECT_GRACE"))
+	}
+	if c.FollowCountsReconcileInterval < 0 {
+		errs = append(errs, errors.New("FOLLOW_COUNTS_RECONCILE_INTERVAL must not be negative"))
+	}
+	if c.EmailChangeTTL <= 0 {
+		errs = append(errs, errors.New("EMAIL_CHANGE_TTL must be positive"))
+	}
+	if c.UserCacheTTL <= 0 {
+		errs = append(errs, errors.New("USER_CACHE_TTL must be positive"))
+	}
+	if c.Password.ResetTTL <= 0 {
+		errs = append(errs, errors.New("PASSWORD_RESET_TTL must be positive"))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
 
-	TokenKey string
+// apply merges one layer into values. A KEY_FILE entry is resolved right away
+// so it competes with KEY at the precedence of the layer it came from.
+func apply(values, layer map[string]string) error {
+	for key, v := range layer {
+		if name, ok := strings.CutSuffix(key, "_FILE"); ok && isSecret(name) {
+			data, err := os.ReadFile(v)
+			if err != nil {
+				return fmt.Errorf("config: reading %s: %w", key, err)
+			}
+			values[name] = strings.TrimSpace(string(data))
+		}
+	}
+	for key, v := range layer {
+		if isKey(key) {
+			if _, fromFile := layer[key+"_FILE"]; fromFile {
+				continue
+			}
+			values[key] = v
+		}
+	}
+	return nil
 }
 
-func Load() Config {
-	if err := godotenv.Load(); err != nil {
-		fmt.Println("No .env file found")
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
 	}
 
-	config := Config{}
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config: unsupported config file type %q", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: parsing %s: %w", path, err)
+	}
 
-	config.HTTPPort = cast.ToString(getOrReturnDefaultValue("HTTP_PORT", ":8081"))
+	values := make(map[string]string)
+	flatten("", raw, values)
+	for key := range values {
+		if !isKey(key) && !isSecret(strings.TrimSuffix(key, "_FILE")) {
+			return nil, fmt.Errorf("config: unknown key %q in %s", key, path)
+		}
+	}
+	return values, nil
+}
 
-	config.PostgresHost = cast.ToString(getOrReturnDefaultValue("POSTGRES_HOST", "localhost"))
-	config.PostgresPort = cast.ToInt(getOrReturnDefaultValue("POSTGRES_PORT", 5432))
-	config.PostgresUser = cast.ToString(getOrReturnDefaultValue("POSTGRES_USER", "macbookpro"))
-	config.PostgresPassword = cast.ToString(getOrReturnDefaultValue("POSTGRES_PASSWORD", "1111"))
-	config.PostgresDatabase = cast.ToString(getOrReturnDefaultValue("POSTGRES_DATABASE", "content"))
-	config.TokenKey = cast.ToString(getOrReturnDefaultValue("TokenKey", "my_secret_key"))
-	return config
+func flatten(prefix string, raw map[string]any, out map[string]string) {
+	for k, v := range raw {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		if nested, ok := v.(map[string]any); ok {
+			flatten(key, nested, out)
+			continue
+		}
+		out[key] = cast.ToString(v)
+	}
 }
 
-func getOrReturnDefaultValue(key string, defaultValue interface{}) interface{} {
-	val, exists := os.LookupEnv(key)
+func isKey(key string) bool {
+	for _, f := range fields {
+		if f.key == key {
+			return true
+		}
+	}
+	return false
+}
 
-	if exists {
-		return val
+func isSecret(key string) bool {
+	for _, f := range fields {
+		if f.key == key {
+			return f.secret
+		}
 	}
+	return false
+}
 
-	return defaultValue
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
 }
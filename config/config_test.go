@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	keyFile := writeFile(t, "refresh_key", "refresh-from-file\n")
+	cfgFile := writeFile(t, "config.yaml", `
+http_port: ":9000"
+grpc_port: ":9001"
+postgres:
+  host: db.internal
+  password: from-yaml
+token:
+  access_key: access-from-yaml
+  refresh_key_file: `+keyFile+`
+  access_ttl: 15m
+`)
+
+	t.Setenv("POSTGRES_HOST", "db.env")
+	t.Setenv("GRPC_PORT", ":9101")
+
+	cfg, err := Load([]string{"-config", cfgFile, "-grpc-port", ":9201"})
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9000", cfg.HTTPPort)
+	assert.Equal(t, ":9201", cfg.GRPCPort)
+	assert.Equal(t, "db.env", cfg.Postgres.Host)
+	assert.Equal(t, 5432, cfg.Postgres.Port)
+	assert.Equal(t, "from-yaml", cfg.Postgres.Password)
+	assert.Equal(t, "access-from-yaml", cfg.Token.AccessKey)
+	assert.Equal(t, "refresh-from-file", cfg.Token.RefreshKey)
+	assert.Equal(t, 15*time.Minute, cfg.Token.AccessTTL)
+	assert.Equal(t, 24*time.Hour, cfg.Token.RefreshTTL)
+}
+
+func TestLoadSecretFileFromEnv(t *testing.T) {
+	cfgFile := writeFile(t, "config.toml", `
+[postgres]
+password = "from-toml"
+
+[token]
+access_key = "a"
+refresh_key = "r"
+`)
+	t.Setenv("POSTGRES_PASSWORD_FILE", writeFile(t, "pg", "from-secret"))
+
+	cfg, err := Load([]string{"-config", cfgFile})
+	require.NoError(t, err)
+	assert.Equal(t, "from-secret", cfg.Postgres.Password)
+}
+
+func TestLoadMissingSecrets(t *testing.T) {
+	_, err := Load(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "POSTGRES_PASSWORD")
+	assert.Contains(t, err.Error(), "TOKEN_ACCESS_KEY")
+	assert.Contains(t, err.Error(), "TOKEN_REFRESH_KEY")
+}
+
+func TestLoadUnknownFileKey(t *testing.T) {
+	cfgFile := writeFile(t, "config.yaml", "postgres:\n  hots: localhost\n")
+
+	_, err := Load([]string{"-config", cfgFile})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "POSTGRES_HOTS")
+}
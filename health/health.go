@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"Auth-Service/genproto/users"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// SchemaVersion is the migration version this build expects. Bump it together
+// with every new file in migrations/.
+const SchemaVersion = 13
+
+const checkTimeout = 2 * time.Second
+
+type Check struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status           string           `json:"status"`
+	MigrationVersion int              `json:"migration_version"`
+	Checks           map[string]Check `json:"checks"`
+}
+
+// Checker tracks whether the service can take traffic. The same state backs
+// the HTTP /readyz endpoint and the grpc.health.v1 service.
+type Checker struct {
+	db       *sql.DB
+	rdb      *redis.Client
+	grpc     *health.Server
+	shutdown atomic.Bool
+}
+
+func NewChecker(db *sql.DB, rdb *redis.Client) *Checker {
+	return &Checker{
+		db:   db,
+		rdb:  rdb,
+		grpc: health.NewServer(),
+	}
+}
+
+// GRPCServer returns the grpc.health.v1 implementation to register on the
+// gRPC server.
+func (c *Checker) GRPCServer() healthpb.HealthServer {
+	return c.grpc
+}
+
+// Ready runs all dependency checks. It never returns an error; failures are
+// reported per check and reflected in Report.Status.
+func (c *Checker) Ready(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	report := Report{Status: "ok", Checks: map[string]Check{}}
+	if c.shutdown.Load() {
+		report.Status = "shutting_down"
+		return report
+	}
+
+	report.Checks["postgres"] = result(c.db.PingContext(ctx))
+	report.Checks["redis"] = result(c.rdb.Ping(ctx).Err())
+
+	version, err := c.migrationVersion(ctx)
+	report.MigrationVersion = version
+	report.Checks["migrations"] = result(err)
+
+	for _, check := range report.Checks {
+		if check.Status != "ok" {
+			report.Status = "unavailable"
+		}
+	}
+	return report
+}
+
+func (c *Checker) migrationVersion(ctx context.Context) (int, error) {
+	var (
+		version int
+		dirty   bool
+	)
+	err := c.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return version, fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < SchemaVersion {
+		return version, fmt.Errorf("schema is at version %d, want %d", version, SchemaVersion)
+	}
+	return version, nil
+}
+
+// Watch keeps the gRPC serving status in line with Ready until ctx is done.
+func (c *Checker) Watch(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.update(ctx, logger)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) update(ctx context.Context, logger *zap.Logger) {
+	if c.shutdown.Load() {
+		return
+	}
+
+	status := healthpb.HealthCheckResponse_SERVING
+	if report := c.Ready(ctx); report.Status != "ok" {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+		logger.Warn("readiness check failed", zap.Any("checks", report.Checks))
+	}
+	c.grpc.SetServingStatus("", status)
+	c.grpc.SetServingStatus(users.UserService_ServiceDesc.ServiceName, status)
+}
+
+// Shutdown marks the service as not ready so load balancers stop routing to
+// it while in-flight requests drain. It cannot be undone.
+func (c *Checker) Shutdown() {
+	c.shutdown.Store(true)
+	c.grpc.Shutdown()
+}
+
+func result(err error) Check {
+	if err != nil {
+		return Check{Status: "error", Error: err.Error()}
+	}
+	return Check{Status: "ok"}
+}
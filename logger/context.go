@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RequestIDKey is the HTTP header and gRPC metadata key carrying the request
+// ID between services.
+const RequestIDKey = "x-request-id"
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// WithContext returns a copy of ctx carrying l.
+func WithContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the request-scoped logger stored in ctx, or fallback if
+// there is none.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+// With adds fields to the logger stored in ctx.
+func With(ctx context.Context, fallback *zap.Logger, fields ...zap.Field) context.Context {
+	return WithContext(ctx, FromContext(ctx, fallback).With(fields...))
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns incoming if it looks like a usable ID and a fresh UUID
+// otherwise, so clients cannot inject arbitrary data into logs.
+func NewRequestID(incoming string) string {
+	if incoming != "" && len(incoming) <= 128 && printable(incoming) {
+		return incoming
+	}
+	return uuid.NewString()
+}
+
+func printable(s string) bool {
+	for _, r := range s {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
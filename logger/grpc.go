@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor assigns every call a request ID (reusing the one in
+// the incoming metadata when present), returns it in the response header and
+// binds a logger carrying it to the call context.
+func UnaryServerInterceptor(base *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var incoming string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(RequestIDKey); len(values) > 0 {
+				incoming = values[0]
+			}
+		}
+		id := NewRequestID(incoming)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+
+		l := base.With(zap.String("request_id", id), zap.String("grpc_method", info.FullMethod))
+		ctx = WithContext(WithRequestID(ctx, id), l)
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		fields := []zap.Field{zap.String("code", code.String()), zap.Duration("latency", time.Since(start))}
+		if err != nil {
+			FromContext(ctx, l).Warn("grpc call failed", append(fields, zap.Error(err))...)
+		} else {
+			FromContext(ctx, l).Info("grpc call completed", fields...)
+		}
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor forwards the request ID of the current call to
+// downstream services.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := RequestID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
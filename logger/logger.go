@@ -1,13 +1,18 @@
 package logger
 
 import (
+	"os"
+	"time"
+
+	"Auth-Service/config"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"os"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func NewLogger() (*zap.Logger, error) {
-	logFile, err := os.Create("app.log")
+func NewLogger(cfg config.LogConfig) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(cfg.Level)
 	if err != nil {
 		return nil, err
 	}
@@ -25,12 +30,41 @@ func NewLogger() (*zap.Logger, error) {
 		EncodeDuration: zapcore.StringDurationEncoder,
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
-	encoder := zapcore.NewJSONEncoder(encoderConfig)
-	fileWriteSyncer := zapcore.AddSync(logFile)
-	consoleWriteSyncer := zapcore.AddSync(os.Stdout)
-	multiWriteSyncer := zapcore.NewMultiWriteSyncer(fileWriteSyncer, consoleWriteSyncer)
-	core := zapcore.NewCore(encoder, multiWriteSyncer, zapcore.DebugLevel)
+	var encoder zapcore.Encoder
+	if cfg.Format == "console" {
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	} else {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	}
 
-	logger := zap.New(core)
+	writeSyncers := []zapcore.WriteSyncer{zapcore.AddSync(os.Stdout)}
+	if cfg.File != "" {
+		logFile := &lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxAge:     cfg.MaxAgeDays,
+			MaxBackups: cfg.MaxBackups,
+			LocalTime:  true,
+		}
+		if cfg.RotateInterval > 0 {
+			go rotateEvery(logFile, cfg.RotateInterval)
+		}
+		writeSyncers = append(writeSyncers, zapcore.AddSync(logFile))
+	}
+
+	core := zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(writeSyncers...), level)
+
+	logger := zap.New(&redactingCore{Core: core}, zap.AddCaller())
 	return logger, nil
-}
\ No newline at end of file
+}
+
+// rotateEvery adds time-based rotation on top of lumberjack's size-based
+// rotation. It runs for the lifetime of the process.
+func rotateEvery(logFile *lumberjack.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_ = logFile.Rotate()
+	}
+}
@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)bearer\s+\S+`)
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// sensitiveKeys are matched as substrings of lower-cased field names.
+var sensitiveKeys = []string{"password", "token", "authorization", "secret", "email"}
+
+// redactingCore scrubs tokens, passwords and email addresses from messages
+// and fields before they reach any sink, so call sites cannot leak them by
+// accident (e.g. by logging err.Error() of a failed INSERT). Objects, arrays
+// and reflected values are scrubbed in their encoded form, nested keys
+// included; numbers, times and durations are left alone.
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = RedactString(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+// RedactString masks JWTs, bearer credentials and email addresses in s.
+func RedactString(s string) string {
+	s = bearerPattern.ReplaceAllString(s, "Bearer "+redacted)
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	s = emailPattern.ReplaceAllString(s, redacted)
+	return s
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		out[i] = redactField(f)
+	}
+	return out
+}
+
+func redactField(f zapcore.Field) zapcore.Field {
+	if isSensitive(f.Key) {
+		return zap.String(f.Key, redacted)
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = RedactString(f.String)
+	case zapcore.ByteStringType:
+		if b, ok := f.Interface.([]byte); ok {
+			return zap.String(f.Key, RedactString(string(b)))
+		}
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok {
+			return zap.String(f.Key, RedactString(err.Error()))
+		}
+	case zapcore.StringerType:
+		if s, ok := f.Interface.(interface{ String() string }); ok {
+			return zap.String(f.Key, RedactString(s.String()))
+		}
+	case zapcore.ReflectType:
+		// Round-trip through JSON, the form the encoders would write, so
+		// nested strings and keys can be scrubbed like top-level ones.
+		data, err := json.Marshal(f.Interface)
+		var v any
+		if err != nil || json.Unmarshal(data, &v) != nil {
+			return zap.String(f.Key, redacted)
+		}
+		return zap.Any(f.Key, redactValue(v))
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		return zap.Any(f.Key, redactValue(enc.Fields[f.Key]))
+	}
+	return f
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue scrubs the strings and sensitive keys of a decoded object or
+// array.
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return RedactString(v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			if isSensitive(k) {
+				out[k] = redacted
+			} else {
+				out[k] = redactValue(e)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = redactValue(e)
+		}
+		return out
+	}
+	return v
+}
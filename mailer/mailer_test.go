@@ -0,0 +1,133 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"Auth-Service/config"
+	"Auth-Service/notification"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRenderLocaleFallback(t *testing.T) {
+	templates, err := LoadTemplates("", "en")
+	require.NoError(t, err)
+
+	data := map[string]any{"Link": "https://example.com/r?token=abc", "ExpiresAt": "2026-01-02T03:04:05Z"}
+
+	en, err := templates.Render(PasswordReset, "fr-CA", data)
+	require.NoError(t, err)
+	assert.Equal(t, "Reset your password", en.Subject)
+	assert.Contains(t, en.Text, "https://example.com/r?token=abc")
+	assert.Contains(t, en.Text, "2026-01-02 03:04 UTC")
+	assert.Contains(t, en.HTML, `<html lang="en">`)
+	assert.Contains(t, en.HTML, "<title>Reset your password</title>")
+
+	uz, err := templates.Render(PasswordReset, "uz_UZ", data)
+	require.NoError(t, err)
+	assert.Equal(t, "Parolni tiklash", uz.Subject)
+	assert.Contains(t, uz.HTML, `<html lang="uz">`)
+
+	_, err = templates.Render("missing", "en", data)
+	assert.Error(t, err)
+}
+
+func TestNotificationTemplates(t *testing.T) {
+	templates, err := LoadTemplates("", "en")
+	require.NoError(t, err)
+
+	for _, eventType := range notification.Types() {
+		msg, err := templates.Render(eventType, "", map[string]any{"ActorUsername": "alice", "Link": "https://example.com"})
+		if assert.NoError(t, err, eventType) {
+			assert.NotEmpty(t, msg.Subject, eventType)
+		}
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	templates, err := LoadTemplates("", "en")
+	require.NoError(t, err)
+
+	msg, err := templates.Render(EmailChangeNotice, "", map[string]any{"NewEmail": "<b>x</b>@example.com", "Link": "https://example.com"})
+	require.NoError(t, err)
+	assert.NotContains(t, msg.HTML, "<b>x</b>")
+	assert.Contains(t, msg.Text, "<b>x</b>")
+}
+
+func TestEncode(t *testing.T) {
+	data, err := encode("no-reply@example.com", Message{To: "alice@example.com", Subject: "Hi\r\nBcc: evil@example.com", Text: "hello", HTML: "<p>hello</p>"})
+	require.NoError(t, err)
+
+	header, _, _ := strings.Cut(string(data), "\r\n\r\n")
+	assert.NotContains(t, header, "\r\nBcc:")
+	assert.Contains(t, header, "Content-Type: multipart/alternative")
+
+	_, err = encode("no-reply@example.com", Message{To: "not an address"})
+	assert.Error(t, err)
+}
+
+type fakeStore struct {
+	batch   []Envelope
+	sent    []int64
+	retryAt map[int64]time.Time
+}
+
+func (s *fakeStore) ClaimEmails(_ context.Context, limit int, _ time.Duration) ([]Envelope, error) {
+	batch := s.batch
+	s.batch = nil
+	return batch, nil
+}
+
+func (s *fakeStore) MarkEmailSent(_ context.Context, id int64) error {
+	s.sent = append(s.sent, id)
+	return nil
+}
+
+func (s *fakeStore) MarkEmailFailed(_ context.Context, id int64, _ string, retryAt time.Time) error {
+	s.retryAt[id] = retryAt
+	return nil
+}
+
+type mailerFunc func(Message) error
+
+func (f mailerFunc) Send(_ context.Context, msg Message) error { return f(msg) }
+
+func TestWorkerRetriesAndGivesUp(t *testing.T) {
+	templates, err := LoadTemplates("", "en")
+	require.NoError(t, err)
+
+	store := &fakeStore{retryAt: map[int64]time.Time{}, batch: []Envelope{
+		{ID: 1, To: "ok@example.com", Template: PasswordReset, Attempts: 1},
+		{ID: 2, To: "down@example.com", Template: PasswordReset, Attempts: 1},
+		{ID: 3, To: "down@example.com", Template: PasswordReset, Attempts: 3},
+		{ID: 4, To: "ok@example.com", Template: "missing", Attempts: 1},
+	}}
+	send := mailerFunc(func(msg Message) error {
+		if msg.To == "down@example.com" {
+			return errors.New("relay unavailable")
+		}
+		return nil
+	})
+	cfg := config.MailConfig{BatchSize: 10, MaxAttempts: 3, RetryBase: time.Minute, RetryMax: time.Hour}
+
+	NewWorker(store, send, templates, cfg, zap.NewNop()).drain(context.Background())
+
+	assert.Equal(t, []int64{1}, store.sent)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), store.retryAt[2], 5*time.Second)
+	assert.True(t, store.retryAt[3].IsZero(), "last attempt is not retried")
+	assert.True(t, store.retryAt[4].IsZero(), "render failures are not retried")
+}
+
+func TestBackoff(t *testing.T) {
+	w := &Worker{cfg: config.MailConfig{RetryBase: 30 * time.Second, RetryMax: 5 * time.Minute}}
+
+	assert.Equal(t, 30*time.Second, w.backoff(1))
+	assert.Equal(t, 2*time.Minute, w.backoff(3))
+	assert.Equal(t, 5*time.Minute, w.backoff(10))
+}
@@ -0,0 +1,187 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var builtin embed.FS
+
+const layoutFile = "layout.html"
+
+// Templates holds the parsed mail templates. Every message is a
+// NAME[.LOCALE].txt file that defines a "subject" template and renders the
+// text body, plus an optional NAME[.LOCALE].html file that defines the
+// "content" of layout.html. Files without a locale are the fallback.
+type Templates struct {
+	defaultLocale string
+	text          map[string]*texttemplate.Template
+	html          map[string]*htmltemplate.Template
+}
+
+var funcs = map[string]any{
+	// date formats an RFC 3339 timestamp, which is how times survive the
+	// JSON round trip through the outbox.
+	"date": func(v any) string {
+		s := fmt.Sprint(v)
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return s
+		}
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	},
+}
+
+// LoadTemplates parses the templates in dir, or the built-in ones when dir
+// is empty. All of them are parsed up front so a broken template stops the
+// service from starting instead of failing deliveries.
+func LoadTemplates(dir, defaultLocale string) (*Templates, error) {
+	var fsys fs.FS
+	if dir == "" {
+		sub, err := fs.Sub(builtin, "templates")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	} else {
+		fsys = os.DirFS(dir)
+	}
+
+	layout, err := fs.ReadFile(fsys, layoutFile)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: %w", err)
+	}
+	base, err := htmltemplate.New(layoutFile).Funcs(funcs).Parse(string(layout))
+	if err != nil {
+		return nil, fmt.Errorf("mailer: %w", err)
+	}
+
+	t := &Templates{
+		defaultLocale: normalizeLocale(defaultLocale),
+		text:          make(map[string]*texttemplate.Template),
+		html:          make(map[string]*htmltemplate.Template),
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("mailer: %w", err)
+	}
+	for _, e := range entries {
+		file := e.Name()
+		if e.IsDir() || file == layoutFile {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("mailer: %w", err)
+		}
+		key := templateKey(strings.TrimSuffix(file, path.Ext(file)))
+		switch path.Ext(file) {
+		case ".txt":
+			tmpl, err := texttemplate.New(file).Funcs(funcs).Parse(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("mailer: %w", err)
+			}
+			if tmpl.Lookup("subject") == nil {
+				return nil, fmt.Errorf("mailer: %s does not define a subject", file)
+			}
+			t.text[key] = tmpl
+		case ".html":
+			tmpl, err := htmltemplate.Must(base.Clone()).Parse(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("mailer: %s: %w", file, err)
+			}
+			t.html[key] = tmpl
+		}
+	}
+	for key := range t.html {
+		if _, ok := t.text[key]; !ok {
+			return nil, fmt.Errorf("mailer: %s.html has no matching .txt template", key)
+		}
+	}
+	return t, nil
+}
+
+// Render renders template name for the recipient's locale, falling back to
+// the bare language ("pt" for "pt-BR"), then the default locale and finally
+// the files without a locale. data is not modified.
+func (t *Templates) Render(name, locale string, data map[string]any) (Message, error) {
+	for _, candidate := range t.locales(locale) {
+		key := templateKey(name + "." + candidate)
+		if candidate == "" {
+			key = name
+		}
+		text, ok := t.text[key]
+		if !ok {
+			continue
+		}
+
+		vars := make(map[string]any, len(data)+2)
+		for k, v := range data {
+			vars[k] = v
+		}
+		vars["Locale"] = candidate
+		if candidate == "" {
+			vars["Locale"] = t.defaultLocale
+		}
+
+		var subject, body bytes.Buffer
+		if err := text.ExecuteTemplate(&subject, "subject", vars); err != nil {
+			return Message{}, fmt.Errorf("mailer: %w", err)
+		}
+		if err := text.Execute(&body, vars); err != nil {
+			return Message{}, fmt.Errorf("mailer: %w", err)
+		}
+		msg := Message{
+			Subject: strings.TrimSpace(subject.String()),
+			Text:    body.String(),
+		}
+
+		if html, ok := t.html[key]; ok {
+			vars["Subject"] = msg.Subject
+			var out bytes.Buffer
+			if err := html.ExecuteTemplate(&out, layoutFile, vars); err != nil {
+				return Message{}, fmt.Errorf("mailer: %w", err)
+			}
+			msg.HTML = out.String()
+		}
+		return msg, nil
+	}
+	return Message{}, fmt.Errorf("mailer: no template %q", name)
+}
+
+func (t *Templates) locales(locale string) []string {
+	locale = normalizeLocale(locale)
+	var out []string
+	if locale != "" {
+		out = append(out, locale)
+		if lang, _, ok := strings.Cut(locale, "-"); ok {
+			out = append(out, lang)
+		}
+	}
+	if t.defaultLocale != "" {
+		out = append(out, t.defaultLocale)
+	}
+	return append(out, "")
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// templateKey normalizes the locale part of NAME.LOCALE.
+func templateKey(s string) string {
+	name, locale, ok := strings.Cut(s, ".")
+	if !ok {
+		return s
+	}
+	return name + "." + normalizeLocale(locale)
+}
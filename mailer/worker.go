@@ -0,0 +1,144 @@
+package mailer
+
+import (
+	"context"
+	"time"
+
+	"Auth-Service/config"
+	"Auth-Service/metrics"
+
+	"go.uber.org/zap"
+)
+
+// sendTimeout bounds a single delivery. A claimed batch stays leased long
+// enough for every message in it to time out once.
+const sendTimeout = 30 * time.Second
+
+// Envelope is a message queued in the outbox, rendered only when it is
+// delivered so template fixes apply to mail that is still waiting.
+type Envelope struct {
+	ID       int64
+	To       string
+	Template string
+	Locale   string
+	Data     map[string]any
+	// Attempts counts deliveries tried so far, the current one included.
+	Attempts int
+}
+
+// Store is the outbox drained by the Worker.
+type Store interface {
+	// ClaimEmails leases up to limit due messages so other workers skip
+	// them until lease expires, and counts the attempt.
+	ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]Envelope, error)
+	MarkEmailSent(ctx context.Context, id int64) error
+	// MarkEmailFailed records reason and schedules another attempt at
+	// retryAt, or gives up on the message when retryAt is zero.
+	MarkEmailFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error
+}
+
+// Worker delivers queued mail, retrying failures with exponential backoff.
+type Worker struct {
+	store     Store
+	mailer    Mailer
+	templates *Templates
+	cfg       config.MailConfig
+	logger    *zap.Logger
+}
+
+func NewWorker(store Store, mailer Mailer, templates *Templates, cfg config.MailConfig, logger *zap.Logger) *Worker {
+	return &Worker{store: store, mailer: mailer, templates: templates, cfg: cfg, logger: logger}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		w.drain(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain delivers batches until the outbox has no due messages left.
+func (w *Worker) drain(ctx context.Context) {
+	lease := sendTimeout*time.Duration(w.cfg.BatchSize) + time.Minute
+	for ctx.Err() == nil {
+		batch, err := w.store.ClaimEmails(ctx, w.cfg.BatchSize, lease)
+		if err != nil {
+			if ctx.Err() == nil {
+				w.logger.Warn("failed to claim outbox emails", zap.Error(err))
+			}
+			return
+		}
+		for _, env := range batch {
+			w.deliver(ctx, env)
+		}
+		if len(batch) < w.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+func (w *Worker) deliver(ctx context.Context, env Envelope) {
+	log := w.logger.With(zap.Int64("email_id", env.ID), zap.String("template", env.Template), zap.Int("attempt", env.Attempts))
+
+	msg, err := w.templates.Render(env.Template, env.Locale, env.Data)
+	if err != nil {
+		// Rendering again will not help until the templates change.
+		w.fail(ctx, log, env, err, time.Time{})
+		return
+	}
+	msg.To = env.To
+
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	err = w.mailer.Send(sendCtx, msg)
+	cancel()
+	if err != nil {
+		var retryAt time.Time
+		if env.Attempts < w.cfg.MaxAttempts {
+			retryAt = time.Now().Add(w.backoff(env.Attempts))
+		}
+		w.fail(ctx, log, env, err, retryAt)
+		return
+	}
+
+	// The message is out; record it even if shutdown has begun.
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := w.store.MarkEmailSent(storeCtx, env.ID); err != nil {
+		log.Error("email sent but not marked, it may be sent again", zap.Error(err))
+	}
+	metrics.EmailDeliveries.WithLabelValues(metrics.OutcomeSuccess).Inc()
+}
+
+func (w *Worker) fail(ctx context.Context, log *zap.Logger, env Envelope, cause error, retryAt time.Time) {
+	outcome := metrics.OutcomeRetry
+	if retryAt.IsZero() {
+		outcome = metrics.OutcomeFailure
+		log.Error("giving up on email", zap.Error(cause))
+	} else {
+		log.Warn("email delivery failed, will retry", zap.Error(cause), zap.Time("retry_at", retryAt))
+	}
+	metrics.EmailDeliveries.WithLabelValues(outcome).Inc()
+
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := w.store.MarkEmailFailed(storeCtx, env.ID, cause.Error(), retryAt); err != nil {
+		log.Error("failed to record email failure", zap.Error(err))
+	}
+}
+
+// backoff is the delay after the given failed attempt: RetryBase doubled
+// for every earlier attempt, capped at RetryMax.
+func (w *Worker) backoff(attempt int) time.Duration {
+	d := w.cfg.RetryBase
+	for i := 1; i < attempt && d < w.cfg.RetryMax; i++ {
+		d *= 2
+	}
+	return min(d, w.cfg.RetryMax)
+}
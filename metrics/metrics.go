@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+)
+
+const namespace = "auth_service"
+
+// Registry holds every collector exposed on /metrics. A private registry keeps
+// tests and multiple instances in one process from clashing over the global
+// default one.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	GRPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "gRPC requests by full method name and status code.",
+	}, []string{"method", "code"})
+
+	GRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "gRPC request latency by full method name.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	Registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "User registrations by outcome.",
+	}, []string{"outcome"})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by outcome.",
+	}, []string{"outcome"})
+
+	Refreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_refreshes_total",
+		Help:      "Access token refreshes by outcome.",
+	}, []string{"outcome"})
+
+	TokenValidationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_validation_failures_total",
+		Help:      "Rejected access tokens by reason.",
+	}, []string{"reason"})
+
+	Follows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "follows_total",
+		Help:      "Follow requests by outcome.",
+	}, []string{"outcome"})
+
+	Unfollows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "unfollows_total",
+		Help:      "Unfollow requests by outcome.",
+	}, []string{"outcome"})
+
+	ModerationActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "moderation_actions_total",
+		Help:      "Resolved reports by moderation action.",
+	}, []string{"action"})
+
+	EmailDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_deliveries_total",
+		Help:      "Outbox email delivery attempts by outcome: success, retry or failure.",
+	}, []string{"outcome"})
+)
+
+// Outcome label values shared by the domain counters.
+const (
+	OutcomeSuccess            = "success"
+	OutcomeFailure            = "failure"
+	OutcomeInvalidCredentials = "invalid_credentials"
+	OutcomeInvalidToken       = "invalid_token"
+	OutcomeRetry              = "retry"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		GRPCRequests,
+		GRPCDuration,
+		Registrations,
+		Logins,
+		Refreshes,
+		TokenValidationFailures,
+		Follows,
+		Unfollows,
+		ModerationActions,
+		EmailDeliveries,
+	)
+}
+
+// RegisterStores exposes connection pool statistics of the Postgres and Redis
+// clients. Call it once after both are opened.
+func RegisterStores(db *sql.DB, rdb *redis.Client) {
+	Registry.MustRegister(
+		collectors.NewDBStatsCollector(db, "users"),
+		newRedisCollector(rdb),
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Outcome maps an error to the outcome label of the domain counters.
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS users_username_canonical_key;
+DROP INDEX IF EXISTS users_email_canonical_key;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_username_key,
+    DROP CONSTRAINT IF EXISTS users_email_key,
+    DROP COLUMN IF EXISTS username_canonical,
+    DROP COLUMN IF EXISTS email_canonical;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_username_key UNIQUE (username),
+    ADD CONSTRAINT users_email_key UNIQUE (email);
@@ -0,0 +1,12 @@
+-- Usernames and emails become unique by their canonical form (see package
+-- canonical). PostgreSQL cannot compute that form, so existing rows are
+-- left NULL here: run "make canonicalize" right after this migration. It
+-- fills them in from Go, reports every collision at once if there are any,
+-- and then makes the columns NOT NULL and drops the old exact-match
+-- constraints.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS username_canonical VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS email_canonical VARCHAR(100);
+
+CREATE UNIQUE INDEX users_username_canonical_key ON users (username_canonical);
+CREATE UNIQUE INDEX users_email_canonical_key ON users (email_canonical);
@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS username_changed_at TIMESTAMP WITH TIME ZONE;
+
+-- One row per username a user gave up. The old name resolves to the user
+-- until redirect_until and cannot be claimed by anyone else until hold_until.
+CREATE TABLE IF NOT EXISTS username_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id),
+    username VARCHAR(50) NOT NULL,
+    username_canonical VARCHAR(50) NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    redirect_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    hold_until TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS username_history_canonical_idx ON username_history (username_canonical, changed_at DESC);
+CREATE INDEX IF NOT EXISTS username_history_user_id_idx ON username_history (user_id);
@@ -0,0 +1,15 @@
+-- Denormalized follow counts, kept up to date by follow and unfollow and
+-- corrected by the periodic reconcile job. Follows involving deleted users
+-- are not counted.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS followers_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS following_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE users u SET
+    followers_count = (
+        SELECT COUNT(*) FROM followers f JOIN users o ON o.id = f.follower_id AND o.deleted_at IS NULL
+        WHERE f.following_id = u.id
+    ),
+    following_count = (
+        SELECT COUNT(*) FROM followers f JOIN users o ON o.id = f.following_id AND o.deleted_at IS NULL
+        WHERE f.follower_id = u.id
+    );
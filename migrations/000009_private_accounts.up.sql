@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- A pending request to follow a private account.
+CREATE TABLE IF NOT EXISTS follow_requests (
+    requester_id UUID NOT NULL REFERENCES users(id),
+    target_id UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (requester_id, target_id)
+);
+
+CREATE INDEX IF NOT EXISTS follow_requests_target_id_idx ON follow_requests (target_id, created_at DESC);
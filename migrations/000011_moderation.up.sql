@@ -0,0 +1,37 @@
+-- Moderators are appointed by setting role = 'moderator' directly.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reporter_id UUID NOT NULL REFERENCES users(id),
+    reported_id UUID NOT NULL REFERENCES users(id),
+    reason VARCHAR(32) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'open',
+    moderator_id UUID REFERENCES users(id),
+    action VARCHAR(16),
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    claimed_at TIMESTAMP WITH TIME ZONE,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    CHECK (reporter_id <> reported_id)
+);
+
+-- One unresolved report per reporter and reported user.
+CREATE UNIQUE INDEX IF NOT EXISTS reports_open_key ON reports (reporter_id, reported_id) WHERE status <> 'resolved';
+CREATE INDEX IF NOT EXISTS reports_queue_idx ON reports (status, created_at);
+
+-- Who did what to whom, kept for accountability.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id UUID REFERENCES users(id),
+    action VARCHAR(64) NOT NULL,
+    target_id UUID REFERENCES users(id),
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_target_id_idx ON audit_log (target_id, created_at DESC);
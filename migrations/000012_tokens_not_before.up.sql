@@ -0,0 +1,6 @@
+-- Tokens issued at or before tokens_not_before are rejected, so a
+-- suspension, ban or deactivation takes effect before they expire.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_not_before TIMESTAMP WITH TIME ZONE;
+
+UPDATE users SET tokens_not_before = NOW()
+WHERE deleted_at IS NOT NULL OR banned_at IS NOT NULL OR suspended_until > NOW();
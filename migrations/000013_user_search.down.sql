@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_countries_visited_idx;
+DROP INDEX IF EXISTS users_created_at_idx;
+DROP INDEX IF EXISTS users_full_name_trgm_idx;
+DROP INDEX IF EXISTS users_username_trgm_idx;
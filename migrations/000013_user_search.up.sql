@@ -0,0 +1,8 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Trigram indexes serve both the prefix (LIKE) and the fuzzy (%) matches of
+-- user search.
+CREATE INDEX IF NOT EXISTS users_username_trgm_idx ON users USING gin (username_canonical gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_full_name_trgm_idx ON users USING gin (lower(full_name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS users_countries_visited_idx ON users (countries_visited DESC, id DESC);
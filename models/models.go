@@ -1,30 +1,71 @@
 package models
 
+import "Auth-Service/apperrors"
+
 // RegisterRequest represents the registration request payload.
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
-	Email string    `json:"email" binding:"required"`
-	FullName string    `json:"full_name" binding:"required"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email string    `json:"email"`
+	FullName string    `json:"full_name"`
 }
 
 // LoginRequest represents the login request payload.
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username string `json:"username"`
+	Password string `json:"password"`
 }
 
 // UpdateProfileRequest represents the update profile request payload.
+// Fields left out keep their current value; an empty bio clears it.
 type UpdateProfileRequest struct {
-	UserID   string `json:"user_id"`
-	Email    string `json:"email" binding:"required"`
-	FullName string `json:"full_name" binding:"required"`
-	Bio      string `json:"bio"`
+	UserID   string  `json:"user_id"`
+	FullName *string `json:"full_name,omitempty"`
+	Bio      *string `json:"bio,omitempty"`
+	// IsPrivate makes new followers need approval; omit it to keep the
+	// current setting.
+	IsPrivate *bool `json:"is_private,omitempty"`
+}
+
+// ChangePasswordRequest represents the change password request payload.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangeUsernameRequest represents the change username request payload.
+type ChangeUsernameRequest struct {
+	Username string `json:"username"`
+}
+
+// ChangeEmailRequest represents the email change request payload.
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email"`
+	Password string `json:"password"`
+}
+
+// MarkNotificationsReadRequest lists the notifications to mark as read;
+// leaving ids empty marks all of them.
+type MarkNotificationsReadRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// NotificationPreference selects how one event type is delivered.
+type NotificationPreference struct {
+	Type  string `json:"type"`
+	InApp bool   `json:"in_app"`
+	Email bool   `json:"email"`
+}
+
+// NotificationPreferencesRequest represents the notification preferences
+// update payload.
+type NotificationPreferencesRequest struct {
+	Preferences []NotificationPreference `json:"preferences"`
 }
 
 // RefreshRequest represents the refresh token request payload.
 type RefreshRequest struct {
-	RefreshToken string `json:"refresh_token" binding:"required"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 // Tokens represents the tokens response payload.
@@ -47,11 +88,31 @@ type Success struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
-type Failed struct {
-	Message string `json:"message"`
-	Error   string `json:"error"`
+// Problem is an RFC 7807 problem-details body returned for every error.
+type Problem struct {
+	Type      string                     `json:"type"`
+	Title     string                     `json:"title"`
+	Status    int                        `json:"status"`
+	Detail    string                     `json:"detail,omitempty"`
+	Instance  string                     `json:"instance,omitempty"`
+	RequestID string                     `json:"request_id,omitempty"`
+	Errors    []apperrors.FieldViolation `json:"errors,omitempty"`
 }
 
 type Logout struct{
 	Message string `json:"message"`
-}
\ No newline at end of file
+}
+
+// ReportRequest represents a report about another user.
+type ReportRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+// ResolveReportRequest represents a moderator's decision on a report.
+type ResolveReportRequest struct {
+	Action string `json:"action"`
+	// SuspendFor is a duration such as "72h", required to suspend.
+	SuspendFor string `json:"suspend_for,omitempty"`
+	Note       string `json:"note"`
+}
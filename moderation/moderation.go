@@ -0,0 +1,56 @@
+// Package moderation lists why users can be reported and what moderators
+// can do about a report.
+package moderation
+
+import "slices"
+
+// Report reasons.
+const (
+	ReasonFakeProfile   = "fake_profile"
+	ReasonImpersonation = "impersonation"
+	ReasonHarassment    = "harassment"
+	ReasonSpam          = "spam"
+	ReasonInappropriate = "inappropriate_content"
+	ReasonOther         = "other"
+)
+
+// Report statuses. A report is open until a moderator claims it, and claimed
+// until they resolve it.
+const (
+	StatusOpen     = "open"
+	StatusClaimed  = "claimed"
+	StatusResolved = "resolved"
+)
+
+// Actions resolving a report. Warn, suspend and ban apply to the reported
+// user; dismiss leaves them alone.
+const (
+	ActionDismiss = "dismiss"
+	ActionWarn    = "warn"
+	ActionSuspend = "suspend"
+	ActionBan     = "ban"
+)
+
+var (
+	reasons  = []string{ReasonFakeProfile, ReasonHarassment, ReasonImpersonation, ReasonInappropriate, ReasonOther, ReasonSpam}
+	statuses = []string{StatusClaimed, StatusOpen, StatusResolved}
+	actions  = []string{ActionBan, ActionDismiss, ActionSuspend, ActionWarn}
+)
+
+// Reasons returns every report reason in a stable order.
+func Reasons() []string { return slices.Clone(reasons) }
+
+// Statuses returns every report status in a stable order.
+func Statuses() []string { return slices.Clone(statuses) }
+
+// Actions returns every action in a stable order.
+func Actions() []string { return slices.Clone(actions) }
+
+// KnownReason reports whether r is a report reason.
+func KnownReason(r string) bool { return slices.Contains(reasons, r) }
+
+// KnownStatus reports whether s is a report status.
+func KnownStatus(s string) bool { return slices.Contains(statuses, s) }
+
+// KnownAction reports whether a is an action.
+func KnownAction(a string) bool { return slices.Contains(actions, a) }
@@ -0,0 +1,46 @@
+// Package notification lists the events users are notified about and the
+// delivery a user gets for each one unless they changed it.
+package notification
+
+import "sort"
+
+// Event types.
+const (
+	NewFollower    = "new_follower"
+	FollowRequest  = "follow_request"
+	FollowAccepted = "follow_accepted"
+)
+
+// Preference selects how a user hears about one event type. Both false
+// means the event is not delivered at all.
+type Preference struct {
+	InApp bool
+	Email bool
+}
+
+var defaults = map[string]Preference{
+	NewFollower:    {InApp: true, Email: true},
+	FollowRequest:  {InApp: true, Email: true},
+	FollowAccepted: {InApp: true, Email: false},
+}
+
+// Types returns every known event type in a stable order.
+func Types() []string {
+	types := make([]string, 0, len(defaults))
+	for t := range defaults {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Known reports whether t is an event type.
+func Known(t string) bool {
+	_, ok := defaults[t]
+	return ok
+}
+
+// Default is the preference of users that never changed it for t.
+func Default(t string) Preference {
+	return defaults[t]
+}
@@ -0,0 +1,147 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// builtinList holds SHA-1 hashes of the most common passwords and is used
+// when no list is configured.
+//
+//go:embed breached.txt
+var builtinList string
+
+const prefixLen = 5
+
+// BreachedList answers whether a password appears in a breach corpus. Hashes
+// are bucketed by their first five hex digits, the same k-anonymity split
+// used by the Pwned Passwords range API, so a directory of downloaded range
+// files can be loaded as-is.
+type BreachedList struct {
+	buckets map[string][]string
+}
+
+// LoadBreachedList reads path, which is either a single file with one
+// uppercase or lowercase SHA-1 hash per line (optionally followed by
+// ":count"), or a directory of range files named after their five-digit
+// prefix and containing "SUFFIX:count" lines. An empty path loads the
+// built-in list.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	l := &BreachedList{buckets: make(map[string][]string)}
+	if path == "" {
+		if err := l.readHashes(strings.NewReader(builtinList)); err != nil {
+			return nil, err
+		}
+		l.sort()
+		return l, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("password: breached list: %w", err)
+	}
+	if !info.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("password: breached list: %w", err)
+		}
+		defer f.Close()
+		if err := l.readHashes(f); err != nil {
+			return nil, fmt.Errorf("password: breached list %s: %w", path, err)
+		}
+		l.sort()
+		return l, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("password: breached list: %w", err)
+	}
+	for _, e := range entries {
+		prefix := strings.ToUpper(strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())))
+		if e.IsDir() || !isHex(prefix, prefixLen) {
+			continue
+		}
+		if err := l.readRange(filepath.Join(path, e.Name()), prefix); err != nil {
+			return nil, err
+		}
+	}
+	l.sort()
+	return l, nil
+}
+
+// Contains reports whether password is in the list.
+func (l *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes := l.buckets[hash[:prefixLen]]
+	i := sort.SearchStrings(suffixes, hash[prefixLen:])
+	return i < len(suffixes) && suffixes[i] == hash[prefixLen:]
+}
+
+func (l *BreachedList) readHashes(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		hash, _, _ := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if hash == "" || strings.HasPrefix(hash, "#") {
+			continue
+		}
+		hash = strings.ToUpper(hash)
+		if !isHex(hash, sha1.Size*2) {
+			return fmt.Errorf("line %d: not a SHA-1 hash", line)
+		}
+		l.buckets[hash[:prefixLen]] = append(l.buckets[hash[:prefixLen]], hash[prefixLen:])
+	}
+	return sc.Err()
+}
+
+func (l *BreachedList) readRange(path, prefix string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("password: breached list: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		suffix, _, _ := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if suffix == "" {
+			continue
+		}
+		suffix = strings.ToUpper(suffix)
+		if !isHex(suffix, sha1.Size*2-prefixLen) {
+			return fmt.Errorf("password: breached list %s: line %d: not a hash suffix", path, line)
+		}
+		l.buckets[prefix] = append(l.buckets[prefix], suffix)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("password: breached list %s: %w", path, err)
+	}
+	return nil
+}
+
+func (l *BreachedList) sort() {
+	for _, suffixes := range l.buckets {
+		sort.Strings(suffixes)
+	}
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+// Package password enforces the password policy on registration, password
+// changes and resets. Configure must be called once at start-up; until then
+// only the length limits apply.
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"Auth-Service/apperrors"
+	"Auth-Service/config"
+)
+
+// Identity holds account attributes a password must not contain.
+type Identity struct {
+	Username string
+	Email    string
+}
+
+// Policy is a set of password rules built from config.PasswordConfig.
+type Policy struct {
+	cfg      config.PasswordConfig
+	breached *BreachedList
+}
+
+var policy = &Policy{cfg: config.PasswordConfig{MinLength: 8, MaxLength: 128}}
+
+// NewPolicy builds a policy, loading the breached password list when the
+// check is enabled.
+func NewPolicy(cfg config.PasswordConfig) (*Policy, error) {
+	p := &Policy{cfg: cfg}
+	if cfg.BreachedCheck {
+		list, err := LoadBreachedList(cfg.BreachedList)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = list
+	}
+	return p, nil
+}
+
+// Configure replaces the package policy used by Violations and Check.
+func Configure(cfg config.PasswordConfig) error {
+	p, err := NewPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	policy = p
+	return nil
+}
+
+// Violations returns one message per rule that password breaks.
+func (p *Policy) Violations(password string, id Identity) []string {
+	var msgs []string
+	n := len([]rune(password))
+	if n < p.cfg.MinLength {
+		msgs = append(msgs, fmt.Sprintf("must be at least %d characters", p.cfg.MinLength))
+	}
+	if n > p.cfg.MaxLength {
+		msgs = append(msgs, fmt.Sprintf("must be at most %d characters", p.cfg.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !upper {
+		msgs = append(msgs, "must contain an uppercase letter")
+	}
+	if p.cfg.RequireLower && !lower {
+		msgs = append(msgs, "must contain a lowercase letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		msgs = append(msgs, "must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		msgs = append(msgs, "must contain a symbol")
+	}
+
+	if p.cfg.DisallowIdentity {
+		lowered := strings.ToLower(password)
+		if id.Username != "" && strings.Contains(lowered, strings.ToLower(id.Username)) {
+			msgs = append(msgs, "must not contain your username")
+		}
+		local, _, _ := strings.Cut(id.Email, "@")
+		if len(local) >= 3 && strings.Contains(lowered, strings.ToLower(local)) {
+			msgs = append(msgs, "must not contain your email address")
+		}
+	}
+
+	if p.breached != nil && p.breached.Contains(password) {
+		msgs = append(msgs, "has appeared in a data breach, choose a different one")
+	}
+	return msgs
+}
+
+// Violations checks password against the configured policy.
+func Violations(password string, id Identity) []string {
+	return policy.Violations(password, id)
+}
+
+// Check returns a validation error for field listing every broken rule, or
+// nil if password is acceptable.
+func Check(field, password string, id Identity) error {
+	msgs := Violations(password, id)
+	if len(msgs) == 0 {
+		return nil
+	}
+	violations := make([]apperrors.FieldViolation, len(msgs))
+	for i, msg := range msgs {
+		violations[i] = apperrors.FieldViolation{Field: field, Description: msg}
+	}
+	return apperrors.Validation(violations...)
+}
@@ -0,0 +1,61 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Auth-Service/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() config.PasswordConfig {
+	return config.PasswordConfig{
+		MinLength:        8,
+		MaxLength:        64,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireDigit:     true,
+		DisallowIdentity: true,
+		BreachedCheck:    true,
+	}
+}
+
+func TestViolations(t *testing.T) {
+	p, err := NewPolicy(testConfig())
+	require.NoError(t, err)
+
+	id := Identity{Username: "alice", Email: "wanderer@example.com"}
+	assert.Empty(t, p.Violations("Tr4vel-the-World", id))
+	assert.Equal(t, []string{
+		"must be at least 8 characters",
+		"must contain an uppercase letter",
+		"must contain a digit",
+	}, p.Violations("abc", id))
+	assert.Equal(t, []string{"must not contain your username"}, p.Violations("Alice2024!x", id))
+	assert.Equal(t, []string{"must not contain your email address"}, p.Violations("Wanderer99", id))
+	assert.Equal(t, []string{"has appeared in a data breach, choose a different one"}, p.Violations("Password123", id))
+}
+
+func TestLoadBreachedListFile(t *testing.T) {
+	// SHA-1 of "hunter2", lower-cased and with a count as in the Pwned
+	// Passwords ordered-by-hash download.
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	require.NoError(t, os.WriteFile(path, []byte("f3bbbd66a63d4bf1747940578ec3d0103530e21d:17\n"), 0o600))
+
+	l, err := LoadBreachedList(path)
+	require.NoError(t, err)
+	assert.True(t, l.Contains("hunter2"))
+	assert.False(t, l.Contains("hunter3"))
+}
+
+func TestLoadBreachedListRangeDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "F3BBB.txt"), []byte("D66A63D4BF1747940578EC3D0103530E21D:17\r\n"), 0o600))
+
+	l, err := LoadBreachedList(dir)
+	require.NoError(t, err)
+	assert.True(t, l.Contains("hunter2"))
+}
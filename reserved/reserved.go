@@ -0,0 +1,136 @@
+// Package reserved protects usernames that must not be registered: service
+// names, handles impersonating staff and profanity. The built-in list can be
+// extended with a file in the same format via Configure.
+package reserved
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"Auth-Service/canonical"
+)
+
+//go:embed reserved.txt
+var builtin string
+
+// List is a set of reserved names, prefixes, suffixes and substrings.
+type List struct {
+	exact    map[string]struct{}
+	prefix   []string
+	suffix   []string
+	contains []string
+}
+
+var list = mustParse(builtin)
+
+// Load returns the built-in list extended with the entries in path. An empty
+// path returns the built-in list alone.
+func Load(path string) (*List, error) {
+	l, err := parse(strings.NewReader(builtin))
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return l, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reserved: %w", err)
+	}
+	defer f.Close()
+	extra, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("reserved: %s: %w", path, err)
+	}
+	for name := range extra.exact {
+		l.exact[name] = struct{}{}
+	}
+	l.prefix = append(l.prefix, extra.prefix...)
+	l.suffix = append(l.suffix, extra.suffix...)
+	l.contains = append(l.contains, extra.contains...)
+	return l, nil
+}
+
+// Configure replaces the package list used by IsReserved.
+func Configure(path string) error {
+	l, err := Load(path)
+	if err != nil {
+		return err
+	}
+	list = l
+	return nil
+}
+
+// IsReserved reports whether username may not be registered.
+func (l *List) IsReserved(username string) bool {
+	key := key(username)
+	if _, ok := l.exact[key]; ok {
+		return true
+	}
+	for _, term := range l.prefix {
+		if strings.HasPrefix(key, term) {
+			return true
+		}
+	}
+	for _, term := range l.suffix {
+		if strings.HasSuffix(key, term) {
+			return true
+		}
+	}
+	for _, term := range l.contains {
+		if strings.Contains(key, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsReserved checks username against the configured list.
+func IsReserved(username string) bool {
+	return list.IsReserved(username)
+}
+
+// key strips separators so "ad.min" and "ad_min" match "admin".
+func key(username string) string {
+	return strings.NewReplacer(".", "", "_", "").Replace(canonical.Username(username))
+}
+
+func parse(r io.Reader) (*List, error) {
+	l := &List{exact: make(map[string]struct{})}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		leading, trailing := strings.HasPrefix(line, "*"), strings.HasSuffix(line, "*")
+		term := key(strings.Trim(line, "*"))
+		if term == "" {
+			return nil, fmt.Errorf("invalid entry %q", line)
+		}
+		switch {
+		case leading && trailing:
+			l.contains = append(l.contains, term)
+		case leading:
+			l.suffix = append(l.suffix, term)
+		case trailing:
+			l.prefix = append(l.prefix, term)
+		default:
+			l.exact[term] = struct{}{}
+		}
+	}
+	return l, sc.Err()
+}
+
+func mustParse(s string) *List {
+	l, err := parse(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
@@ -0,0 +1,34 @@
+package reserved
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsReserved(t *testing.T) {
+	l, err := Load("")
+	require.NoError(t, err)
+
+	assert.True(t, l.IsReserved("Admin"))
+	assert.True(t, l.IsReserved("ad.min"))
+	assert.True(t, l.IsReserved("support_team"))
+	assert.True(t, l.IsReserved("the_official"))
+	assert.False(t, l.IsReserved("badminton_fan"))
+	assert.False(t, l.IsReserved("alice"))
+}
+
+func TestLoadExtendsBuiltin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reserved.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# local\nceo\n*founder*\n"), 0o600))
+
+	l, err := Load(path)
+	require.NoError(t, err)
+
+	assert.True(t, l.IsReserved("CEO"))
+	assert.True(t, l.IsReserved("real_founder_1"))
+	assert.True(t, l.IsReserved("admin"))
+}
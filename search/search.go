@@ -0,0 +1,68 @@
+// Package search lists how user searches can be sorted and encodes the
+// opaque cursors used to page through their results.
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Sort orders for user searches. Relevance only makes sense with a query
+// and is the default then; otherwise results are sorted by username.
+const (
+	SortRelevance        = "relevance"
+	SortUsername         = "username"
+	SortNewest           = "newest"
+	SortCountriesVisited = "countries_visited"
+)
+
+var sorts = []string{SortCountriesVisited, SortNewest, SortRelevance, SortUsername}
+
+// Sorts returns every sort order in a stable order.
+func Sorts() []string { return slices.Clone(sorts) }
+
+// KnownSort reports whether s is a sort order.
+func KnownSort(s string) bool { return slices.Contains(sorts, s) }
+
+// DefaultSort is the sort order used when none is asked for.
+func DefaultSort(query string) string {
+	if strings.TrimSpace(query) != "" {
+		return SortRelevance
+	}
+	return SortUsername
+}
+
+// ErrInvalidCursor is returned for a cursor that was not produced by
+// Cursor.Encode.
+var ErrInvalidCursor = errors.New("search: invalid cursor")
+
+// Cursor marks the last result of a page: the sort order it belongs to and
+// the sort key and id of that result, so the next page starts right after
+// it even when rows are added or removed in between.
+type Cursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"i"`
+}
+
+// Encode returns the cursor in its opaque form.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode.
+func DecodeCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c Cursor
+	if err = json.Unmarshal(data, &c); err != nil || !KnownSort(c.Sort) || c.ID == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
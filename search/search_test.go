@@ -0,0 +1,23 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := Cursor{Sort: SortNewest, Key: "2026-01-02 03:04:05.123456+00", ID: "5d1c4a2e-0000-4000-8000-000000000001"}
+
+	decoded, err := DecodeCursor(c.Encode())
+
+	assert.NoError(t, err)
+	assert.Equal(t, c, decoded)
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "not base64!", Cursor{Sort: "shuffle", ID: "x"}.Encode(), Cursor{Sort: SortUsername}.Encode()} {
+		_, err := DecodeCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
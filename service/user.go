@@ -1,26 +1,43 @@
 package service
 
 import (
+	"Auth-Service/apperrors"
+	"Auth-Service/genproto/content"
 	pb "Auth-Service/genproto/users"
+	"Auth-Service/metrics"
 	"Auth-Service/storage/postgres"
+	"Auth-Service/storage/redis"
 	"context"
+	"errors"
+
+	"github.com/spf13/cast"
 )
 
 type UserService struct {
-	UserRepo *postgres.UserRepository
+	UserRepo  *postgres.UserRepository
+	Summaries *redis.UserSummaries
+	Content   content.ContentClient
 	pb.UnimplementedUserServiceServer
 }
 
-func NewUserService(repo *postgres.UserRepository) *UserService {
-	return &UserService{UserRepo: repo}
+func NewUserService(repo *postgres.UserRepository, summaries *redis.UserSummaries, content content.ContentClient) *UserService {
+	return &UserService{UserRepo: repo, Summaries: summaries, Content: content}
 }
 
 func (service *UserService) Register(ctx context.Context, in *pb.RegisterRequest) (*pb.RegisterResponse, error) {
-	return service.UserRepo.Register(ctx, in)
+	res, err := service.UserRepo.Register(ctx, in)
+	metrics.Registrations.WithLabelValues(metrics.Outcome(err)).Inc()
+	return res, err
 }
 
 func (service *UserService) Login(ctx context.Context, in *pb.LoginRequest) (*pb.RegisterResponse, error) {
-	return service.UserRepo.Login(ctx, in)
+	res, err := service.UserRepo.Login(ctx, in)
+	outcome := metrics.Outcome(err)
+	if errors.Is(err, apperrors.ErrInvalidCredentials) {
+		outcome = metrics.OutcomeInvalidCredentials
+	}
+	metrics.Logins.WithLabelValues(outcome).Inc()
+	return res, err
 }
 
 func (service *UserService) Profile(ctx context.Context, in *pb.ProfileRequest) (*pb.ProfileResponse, error) {
@@ -35,6 +52,14 @@ func (service *UserService) GetUsers(ctx context.Context, in *pb.GetUsersRequest
 	return service.UserRepo.GetUsers(ctx, in)
 }
 
+func (service *UserService) GetUserByUsername(ctx context.Context, in *pb.GetUserByUsernameRequest) (*pb.PublicProfile, error) {
+	return service.UserRepo.GetUserByUsername(ctx, in)
+}
+
+func (service *UserService) BatchGetUsers(ctx context.Context, in *pb.BatchGetUsersRequest) (*pb.BatchGetUsersResponse, error) {
+	return service.Summaries.BatchGetUsers(ctx, in)
+}
+
 func (service *UserService) DeleteUser(ctx context.Context, in *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
 	return service.UserRepo.DeleteUser(ctx, in)
 }
@@ -43,14 +68,153 @@ func (service *UserService) ResetPassword(ctx context.Context, in *pb.ResetPassw
 	return service.UserRepo.ResetPassword(ctx, in)
 }
 
+func (service *UserService) ChangePassword(ctx context.Context, in *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	return service.UserRepo.ChangePassword(ctx, in)
+}
+
+func (service *UserService) ConfirmPasswordReset(ctx context.Context, in *pb.ConfirmPasswordResetRequest) (*pb.ChangePasswordResponse, error) {
+	return service.UserRepo.ConfirmPasswordReset(ctx, in)
+}
+
+func (service *UserService) CheckUsername(ctx context.Context, in *pb.CheckUsernameRequest) (*pb.CheckUsernameResponse, error) {
+	return service.UserRepo.CheckUsername(ctx, in)
+}
+
+func (service *UserService) ChangeUsername(ctx context.Context, in *pb.ChangeUsernameRequest) (*pb.ChangeUsernameResponse, error) {
+	return service.UserRepo.ChangeUsername(ctx, in)
+}
+
+func (service *UserService) ResolveUsername(ctx context.Context, in *pb.ResolveUsernameRequest) (*pb.ResolveUsernameResponse, error) {
+	return service.UserRepo.ResolveUsername(ctx, in)
+}
+
+func (service *UserService) RequestEmailChange(ctx context.Context, in *pb.RequestEmailChangeRequest) (*pb.EmailChangeResponse, error) {
+	return service.UserRepo.RequestEmailChange(ctx, in)
+}
+
+func (service *UserService) ConfirmEmailChange(ctx context.Context, in *pb.EmailChangeTokenRequest) (*pb.EmailChangeResponse, error) {
+	return service.UserRepo.ConfirmEmailChange(ctx, in)
+}
+
+func (service *UserService) CancelEmailChange(ctx context.Context, in *pb.EmailChangeTokenRequest) (*pb.EmailChangeResponse, error) {
+	return service.UserRepo.CancelEmailChange(ctx, in)
+}
+
 func (service *UserService) Logout(ctx context.Context, in *pb.LogoutRequest) (*pb.LogoutResponse, error) {
 	return service.UserRepo.Logout(ctx, in)
 }
 
+func (service *UserService) Activity(ctx context.Context, in *pb.ActivityRequest) (*pb.ActivityResponse, error) {
+	if service.Content == nil {
+		return nil, apperrors.Unavailable("content service is not configured", nil)
+	}
+
+	stat, err := service.Content.GetUserStat(ctx, &content.GetUserStatReq{UserId: in.UserId})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ActivityResponse{
+		UserId:           in.UserId,
+		StoriesCount:     cast.ToInt32(stat.TotalStories),
+		CommentsCount:    cast.ToInt32(stat.TotalCommentsReceived),
+		LikesReceived:    cast.ToInt32(stat.TotalLikesReceived),
+		CountriesVisited: cast.ToInt32(stat.TotalCountriesVisited),
+	}, nil
+}
+
 func (service *UserService) FollowUser(ctx context.Context, in *pb.FollowRequest) (*pb.FollowResponce, error) {
-	return service.UserRepo.Follow(ctx, in)
+	res, err := service.UserRepo.Follow(ctx, in)
+	metrics.Follows.WithLabelValues(metrics.Outcome(err)).Inc()
+	return res, err
 }
 
 func (service *UserService) FollowersUsers(ctx context.Context, in *pb.FollowersRequest) (*pb.FollowersResponce, error) {
 	return service.UserRepo.FollowersUsers(ctx, in)
-}
\ No newline at end of file
+}
+
+func (service *UserService) UnfollowUser(ctx context.Context, in *pb.FollowRequest) (*pb.FollowResponce, error) {
+	res, err := service.UserRepo.UnfollowUser(ctx, in)
+	metrics.Unfollows.WithLabelValues(metrics.Outcome(err)).Inc()
+	return res, err
+}
+
+func (service *UserService) FollowingUsers(ctx context.Context, in *pb.FollowersRequest) (*pb.FollowersResponce, error) {
+	return service.UserRepo.FollowingUsers(ctx, in)
+}
+
+func (service *UserService) ListFollowRequests(ctx context.Context, in *pb.FollowRequestsRequest) (*pb.FollowRequestsResponse, error) {
+	return service.UserRepo.ListFollowRequests(ctx, in)
+}
+
+func (service *UserService) ApproveFollowRequest(ctx context.Context, in *pb.FollowRequestAction) (*pb.FollowRequestActionResponse, error) {
+	return service.UserRepo.ApproveFollowRequest(ctx, in)
+}
+
+func (service *UserService) RejectFollowRequest(ctx context.Context, in *pb.FollowRequestAction) (*pb.FollowRequestActionResponse, error) {
+	return service.UserRepo.RejectFollowRequest(ctx, in)
+}
+
+func (service *UserService) BlockUser(ctx context.Context, in *pb.RelationshipRequest) (*pb.RelationshipResponse, error) {
+	return service.UserRepo.BlockUser(ctx, in)
+}
+
+func (service *UserService) UnblockUser(ctx context.Context, in *pb.RelationshipRequest) (*pb.RelationshipResponse, error) {
+	return service.UserRepo.UnblockUser(ctx, in)
+}
+
+func (service *UserService) MuteUser(ctx context.Context, in *pb.RelationshipRequest) (*pb.RelationshipResponse, error) {
+	return service.UserRepo.MuteUser(ctx, in)
+}
+
+func (service *UserService) UnmuteUser(ctx context.Context, in *pb.RelationshipRequest) (*pb.RelationshipResponse, error) {
+	return service.UserRepo.UnmuteUser(ctx, in)
+}
+
+func (service *UserService) IsBlocked(ctx context.Context, in *pb.IsBlockedRequest) (*pb.IsBlockedResponse, error) {
+	return service.UserRepo.IsBlocked(ctx, in)
+}
+
+func (service *UserService) ListBlockedUsers(ctx context.Context, in *pb.UserListRequest) (*pb.UserIdsResponse, error) {
+	return service.UserRepo.ListBlockedUsers(ctx, in)
+}
+
+func (service *UserService) ListMutedUsers(ctx context.Context, in *pb.UserListRequest) (*pb.UserIdsResponse, error) {
+	return service.UserRepo.ListMutedUsers(ctx, in)
+}
+
+func (service *UserService) ReportUser(ctx context.Context, in *pb.ReportUserRequest) (*pb.Report, error) {
+	return service.UserRepo.ReportUser(ctx, in)
+}
+
+func (service *UserService) ListReports(ctx context.Context, in *pb.ListReportsRequest) (*pb.ListReportsResponse, error) {
+	return service.UserRepo.ListReports(ctx, in)
+}
+
+func (service *UserService) ClaimReport(ctx context.Context, in *pb.ClaimReportRequest) (*pb.Report, error) {
+	return service.UserRepo.ClaimReport(ctx, in)
+}
+
+func (service *UserService) ResolveReport(ctx context.Context, in *pb.ResolveReportRequest) (*pb.Report, error) {
+	res, err := service.UserRepo.ResolveReport(ctx, in)
+	if err == nil {
+		metrics.ModerationActions.WithLabelValues(in.Action).Inc()
+	}
+	return res, err
+}
+
+func (service *UserService) ListNotifications(ctx context.Context, in *pb.ListNotificationsRequest) (*pb.ListNotificationsResponse, error) {
+	return service.UserRepo.ListNotifications(ctx, in)
+}
+
+func (service *UserService) MarkNotificationsRead(ctx context.Context, in *pb.MarkNotificationsReadRequest) (*pb.MarkNotificationsReadResponse, error) {
+	return service.UserRepo.MarkNotificationsRead(ctx, in)
+}
+
+func (service *UserService) GetNotificationPreferences(ctx context.Context, in *pb.NotificationPreferencesRequest) (*pb.NotificationPreferencesResponse, error) {
+	return service.UserRepo.GetNotificationPreferences(ctx, in)
+}
+
+func (service *UserService) UpdateNotificationPreferences(ctx context.Context, in *pb.UpdateNotificationPreferencesRequest) (*pb.NotificationPreferencesResponse, error) {
+	return service.UserRepo.UpdateNotificationPreferences(ctx, in)
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"Auth-Service/apperrors"
+	"Auth-Service/logger"
+
+	"go.uber.org/zap"
+)
+
+// Account statuses, from the most restrictive down.
+const (
+	AccountDeactivated = "deactivated"
+	AccountBanned      = "banned"
+	AccountSuspended   = "suspended"
+	AccountActive      = "active"
+)
+
+// CutoffCache is told about new token cut-offs as soon as they are
+// committed, so revoked tokens stop working before the cache expires.
+type CutoffCache interface {
+	Remember(ctx context.Context, userID string, notBefore time.Time) error
+}
+
+// accountState holds the columns that decide whether a user may sign in.
+type accountState struct {
+	DeletedAt      sql.NullTime
+	BannedAt       sql.NullTime
+	SuspendedUntil sql.NullTime
+}
+
+// Status is one of the Account* statuses. A suspension that has run out
+// leaves the account active.
+func (a accountState) Status() string {
+	switch {
+	case a.DeletedAt.Valid:
+		return AccountDeactivated
+	case a.BannedAt.Valid:
+		return AccountBanned
+	case a.SuspendedUntil.Valid && a.SuspendedUntil.Time.After(time.Now()):
+		return AccountSuspended
+	}
+	return AccountActive
+}
+
+// Err is Forbidden for any account that is not active, or nil.
+func (a accountState) Err() error {
+	switch a.Status() {
+	case AccountDeactivated:
+		return apperrors.Forbidden("account is deactivated")
+	case AccountBanned:
+		return apperrors.Forbidden("account is banned")
+	case AccountSuspended:
+		return apperrors.Forbidden("account is suspended until " + a.SuspendedUntil.Time.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// CheckAccount returns Forbidden when the user is deactivated, banned or
+// suspended, so tokens are not renewed for them.
+func (repo *UserRepository) CheckAccount(ctx context.Context, userID string) (err error) {
+	ctx, span := startSpan(ctx, "CheckAccount")
+	defer func() { endSpan(span, err) }()
+
+	var state accountState
+	err = repo.Db.QueryRowContext(ctx,
+		"SELECT deleted_at, banned_at, suspended_until FROM users WHERE id = $1",
+		userID,
+	).Scan(&state.DeletedAt, &state.BannedAt, &state.SuspendedUntil)
+	if err != nil {
+		return mapError(err, "user not found")
+	}
+	return state.Err()
+}
+
+// TokensNotBefore returns the time at or before which the user's tokens were
+// issued are no longer accepted, or the zero time when none were revoked.
+// Tokens of unknown users are never accepted.
+func (repo *UserRepository) TokensNotBefore(ctx context.Context, userID string) (_ time.Time, err error) {
+	ctx, span := startSpan(ctx, "TokensNotBefore")
+	defer func() { endSpan(span, err) }()
+
+	var notBefore sql.NullTime
+	err = repo.Db.QueryRowContext(ctx,
+		"SELECT tokens_not_before FROM users WHERE id = $1",
+		userID,
+	).Scan(&notBefore)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Now(), nil
+	}
+	if err != nil {
+		return time.Time{}, mapError(err, "")
+	}
+	return notBefore.Time, nil
+}
+
+// rememberCutoff passes a committed cut-off on to the cache. A failure only
+// delays the revocation until the cached value expires, so it is logged
+// rather than returned.
+func (repo *UserRepository) rememberCutoff(ctx context.Context, userID string, notBefore time.Time) {
+	if repo.TokenCutoffs == nil {
+		return
+	}
+	if err := repo.TokenCutoffs.Remember(ctx, userID, notBefore); err != nil {
+		logger.FromContext(ctx, zap.NewNop()).Warn("failed to cache token cut-off",
+			zap.String("target_id", userID), zap.Error(err))
+	}
+}
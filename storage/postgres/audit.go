@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"Auth-Service/apperrors"
+)
+
+// audit records in tx that actorID did action to targetID, so the entry
+// exists exactly when the change does.
+func audit(ctx context.Context, tx *sql.Tx, actorID, action, targetID string, details map[string]any) error {
+	payload, err := json.Marshal(details)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_log (actor_id, action, target_id, details) VALUES ($1, $2, $3, $4)",
+		actorID, action, targetID, payload,
+	)
+	return mapError(err, "")
+}
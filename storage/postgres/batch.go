@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+
+	"Auth-Service/canonical"
+	pb "Auth-Service/genproto/users"
+	"Auth-Service/logger"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// UserCache holds copies of user summaries that have to be dropped when the
+// user changes.
+type UserCache interface {
+	Forget(ctx context.Context, userIDs ...string) error
+}
+
+// BatchGetUsers looks up users by id and by current username in one query.
+// Deleted users are reported missing like unknown ones. Ids are compared,
+// and reported missing, in their canonical form.
+func (repo *UserRepository) BatchGetUsers(ctx context.Context, request *pb.BatchGetUsersRequest) (_ *pb.BatchGetUsersResponse, err error) {
+	ctx, span := startSpan(ctx, "BatchGetUsers")
+	defer func() { endSpan(span, err) }()
+
+	ids := canonical.UserIDs(request.Ids)
+	usernames := make([]string, len(request.Usernames))
+	for i, name := range request.Usernames {
+		usernames[i] = canonical.Username(name)
+	}
+
+	rows, err := repo.Db.QueryContext(ctx, `
+	SELECT
+	  id,
+	  username,
+	  username_canonical,
+	  full_name,
+	  is_private
+	FROM
+	  users
+	WHERE
+	  deleted_at IS NULL
+	  AND (id = ANY($1::uuid[]) OR username_canonical = ANY($2::text[]))
+	`, pq.Array(ids), pq.Array(usernames))
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer rows.Close()
+
+	response := &pb.BatchGetUsersResponse{Users: []*pb.UserSummary{}}
+	foundIDs := make(map[string]bool)
+	foundNames := make(map[string]bool)
+	for rows.Next() {
+		var u pb.UserSummary
+		var name string
+		if err = rows.Scan(&u.Id, &u.Username, &name, &u.FullName, &u.IsPrivate); err != nil {
+			return nil, mapError(err, "")
+		}
+		foundIDs[u.Id] = true
+		foundNames[name] = true
+		response.Users = append(response.Users, &u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err, "")
+	}
+
+	for _, id := range ids {
+		if !foundIDs[id] {
+			response.MissingIds = append(response.MissingIds, id)
+		}
+	}
+	for i, name := range usernames {
+		if !foundNames[name] {
+			response.MissingUsernames = append(response.MissingUsernames, request.Usernames[i])
+		}
+	}
+	return response, nil
+}
+
+// forgetUsers drops cached copies of changed users. A failure only leaves
+// them stale until the cache expires, so it is logged rather than returned.
+func (repo *UserRepository) forgetUsers(ctx context.Context, userIDs ...string) {
+	if repo.UserCache == nil {
+		return
+	}
+	if err := repo.UserCache.Forget(ctx, userIDs...); err != nil {
+		logger.FromContext(ctx, zap.NewNop()).Warn("failed to drop cached users",
+			zap.Strings("user_ids", userIDs), zap.Error(err))
+	}
+}
@@ -0,0 +1,232 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	pb "Auth-Service/genproto/users"
+)
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// blockBetween reports whether either user blocked the other, and whether
+// it was a.
+func blockBetween(ctx context.Context, q queryRower, a, b string) (blocked, byA bool, err error) {
+	var byB bool
+	err = q.QueryRowContext(ctx, `
+	SELECT
+	  EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2),
+	  EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $2 AND blocked_id = $1)
+	`, a, b).Scan(&byA, &byB)
+	if err != nil {
+		return false, false, mapError(err, "user not found")
+	}
+	return byA || byB, byA, nil
+}
+
+// BlockUser makes req.UserId block req.TargetId. Follows between the two
+// are removed in both directions, as are pending follow requests. Blocking
+// again is a no-op.
+func (repo *UserRepository) BlockUser(ctx context.Context, req *pb.RelationshipRequest) (_ *pb.RelationshipResponse, err error) {
+	ctx, span := startSpan(ctx, "BlockUser")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	var blockedAt time.Time
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO
+	  user_blocks(
+		blocker_id,
+		blocked_id
+	  )
+	SELECT $1, $2
+	WHERE EXISTS (SELECT 1 FROM users WHERE id = $2 AND deleted_at IS NULL)
+	ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	RETURNING
+	  created_at
+	`, req.UserId, req.TargetId).Scan(&blockedAt)
+	switch {
+	case err == nil:
+		if err = severFollows(ctx, tx, req.UserId, req.TargetId); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		// Already blocked, or no such user; followState tells which.
+	default:
+		return nil, mapError(err, "user not found")
+	}
+
+	return commitRelationship(ctx, tx, req)
+}
+
+// UnblockUser lifts a block. Follows removed by the block stay removed.
+func (repo *UserRepository) UnblockUser(ctx context.Context, req *pb.RelationshipRequest) (_ *pb.RelationshipResponse, err error) {
+	ctx, span := startSpan(ctx, "UnblockUser")
+	defer func() { endSpan(span, err) }()
+	return repo.dropRelationship(ctx, req,
+		"DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2")
+}
+
+// MuteUser hides req.TargetId's content from req.UserId. The muted user is
+// not told and nothing else changes between the two.
+func (repo *UserRepository) MuteUser(ctx context.Context, req *pb.RelationshipRequest) (_ *pb.RelationshipResponse, err error) {
+	ctx, span := startSpan(ctx, "MuteUser")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `
+	INSERT INTO
+	  user_mutes(
+		muter_id,
+		muted_id
+	  )
+	SELECT $1, $2
+	WHERE EXISTS (SELECT 1 FROM users WHERE id = $2 AND deleted_at IS NULL)
+	ON CONFLICT (muter_id, muted_id) DO NOTHING
+	`, req.UserId, req.TargetId); err != nil {
+		return nil, mapError(err, "user not found")
+	}
+
+	return commitRelationship(ctx, tx, req)
+}
+
+// UnmuteUser shows req.TargetId's content to req.UserId again.
+func (repo *UserRepository) UnmuteUser(ctx context.Context, req *pb.RelationshipRequest) (_ *pb.RelationshipResponse, err error) {
+	ctx, span := startSpan(ctx, "UnmuteUser")
+	defer func() { endSpan(span, err) }()
+	return repo.dropRelationship(ctx, req,
+		"DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2")
+}
+
+// IsBlocked tells other services whether anything from one of the users may
+// reach the other, such as a message or a comment.
+func (repo *UserRepository) IsBlocked(ctx context.Context, req *pb.IsBlockedRequest) (_ *pb.IsBlockedResponse, err error) {
+	ctx, span := startSpan(ctx, "IsBlocked")
+	defer func() { endSpan(span, err) }()
+
+	blocked, byUser, err := blockBetween(ctx, repo.Db, req.UserId, req.OtherId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.IsBlockedResponse{Blocked: blocked, ByUser: byUser}, nil
+}
+
+// ListBlockedUsers returns the ids of the users req.UserId blocked.
+func (repo *UserRepository) ListBlockedUsers(ctx context.Context, req *pb.UserListRequest) (_ *pb.UserIdsResponse, err error) {
+	ctx, span := startSpan(ctx, "ListBlockedUsers")
+	defer func() { endSpan(span, err) }()
+	return repo.listUserIds(ctx,
+		"SELECT blocked_id FROM user_blocks WHERE blocker_id = $1 ORDER BY created_at DESC, blocked_id",
+		req.UserId)
+}
+
+// ListMutedUsers returns the ids of the users req.UserId muted, the whole
+// list at once so a feed can be filtered with it.
+func (repo *UserRepository) ListMutedUsers(ctx context.Context, req *pb.UserListRequest) (_ *pb.UserIdsResponse, err error) {
+	ctx, span := startSpan(ctx, "ListMutedUsers")
+	defer func() { endSpan(span, err) }()
+	return repo.listUserIds(ctx,
+		"SELECT muted_id FROM user_mutes WHERE muter_id = $1 ORDER BY created_at DESC, muted_id",
+		req.UserId)
+}
+
+func (repo *UserRepository) listUserIds(ctx context.Context, query, userID string) (*pb.UserIdsResponse, error) {
+	rows, err := repo.Db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer rows.Close()
+
+	response := &pb.UserIdsResponse{UserIds: []string{}}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, mapError(err, "")
+		}
+		response.UserIds = append(response.UserIds, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err, "")
+	}
+	return response, nil
+}
+
+// dropRelationship runs the delete of an unblock or unmute. It succeeds when
+// there was nothing to undo.
+func (repo *UserRepository) dropRelationship(ctx context.Context, req *pb.RelationshipRequest, query string) (*pb.RelationshipResponse, error) {
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, query, req.UserId, req.TargetId); err != nil {
+		return nil, mapError(err, "user not found")
+	}
+	return commitRelationship(ctx, tx, req)
+}
+
+// commitRelationship commits tx and reports where the two users stand.
+func commitRelationship(ctx context.Context, tx *sql.Tx, req *pb.RelationshipRequest) (*pb.RelationshipResponse, error) {
+	state, err := followState(ctx, tx, req.UserId, req.TargetId)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+	return &pb.RelationshipResponse{UserId: req.UserId, TargetId: req.TargetId, Relationship: state.Relationship}, nil
+}
+
+// severFollows removes the follows and follow requests between a and b in
+// both directions.
+func severFollows(ctx context.Context, tx *sql.Tx, a, b string) error {
+	rows, err := tx.QueryContext(ctx, `
+	DELETE FROM followers
+	WHERE (follower_id = $1 AND following_id = $2) OR (follower_id = $2 AND following_id = $1)
+	RETURNING follower_id, following_id
+	`, a, b)
+	if err != nil {
+		return mapError(err, "")
+	}
+	type follow struct{ follower, following string }
+	var removed []follow
+	for rows.Next() {
+		var f follow
+		if err = rows.Scan(&f.follower, &f.following); err != nil {
+			rows.Close()
+			return mapError(err, "")
+		}
+		removed = append(removed, f)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return mapError(err, "")
+	}
+
+	for _, f := range removed {
+		if err = adjustFollowCounts(ctx, tx, f.follower, f.following, -1); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	DELETE FROM follow_requests
+	WHERE (requester_id = $1 AND target_id = $2) OR (requester_id = $2 AND target_id = $1)
+	`, a, b)
+	return mapError(err, "")
+}
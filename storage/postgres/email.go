@@ -0,0 +1,175 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"Auth-Service/apperrors"
+	"Auth-Service/canonical"
+	pb "Auth-Service/genproto/users"
+	"Auth-Service/mailer"
+)
+
+var errInvalidEmailToken = apperrors.Validation(apperrors.FieldViolation{Field: "token", Description: "is invalid or has expired"})
+
+// RequestEmailChange starts an email change. A confirmation link is queued
+// for the new address and a notice with a cancel link for the current one;
+// the stored address only changes once the new one is confirmed. Starting a
+// new change cancels any pending one.
+func (repo *UserRepository) RequestEmailChange(ctx context.Context, request *pb.RequestEmailChangeRequest) (_ *pb.EmailChangeResponse, err error) {
+	ctx, span := startSpan(ctx, "RequestEmailChange")
+	defer func() { endSpan(span, err) }()
+	newEmail := canonical.Display(request.NewEmail)
+	newKey := canonical.Email(newEmail)
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	var oldEmail, oldKey, current, locale string
+	err = tx.QueryRowContext(ctx,
+		"SELECT email, email_canonical, password, locale FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE",
+		request.UserId,
+	).Scan(&oldEmail, &oldKey, &current, &locale)
+	if err != nil {
+		return nil, mapError(err, "user not found")
+	}
+	if current != request.Password {
+		return nil, apperrors.Validation(apperrors.FieldViolation{Field: "password", Description: "is incorrect"})
+	}
+	if newKey == oldKey {
+		return nil, apperrors.Validation(apperrors.FieldViolation{Field: "new_email", Description: "must differ from the current email"})
+	}
+
+	var taken bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM users WHERE email_canonical = $1)",
+		newKey,
+	).Scan(&taken)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	if taken {
+		return nil, apperrors.Conflict(conflictMessages["users_email_canonical_key"])
+	}
+
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE email_change_requests SET cancelled_at = NOW() WHERE user_id = $1 AND confirmed_at IS NULL AND cancelled_at IS NULL",
+		request.UserId,
+	); err != nil {
+		return nil, mapError(err, "")
+	}
+
+	confirmToken, err := newLinkToken()
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	cancelToken, err := newLinkToken()
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	expiresAt := time.Now().Add(repo.EmailChangeTTL)
+	if _, err = tx.ExecContext(ctx, `
+	INSERT INTO email_change_requests (user_id, old_email, new_email, new_email_canonical, confirm_token_hash, cancel_token_hash, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, request.UserId, oldEmail, newEmail, newKey, hashLinkToken(confirmToken), hashLinkToken(cancelToken), expiresAt,
+	); err != nil {
+		return nil, mapError(err, "")
+	}
+	if err = enqueueEmail(ctx, tx, newEmail, mailer.EmailChangeConfirm, locale, map[string]any{
+		"NewEmail":  newEmail,
+		"Link":      repo.PublicURL + "/confirm-email-change?token=" + confirmToken,
+		"ExpiresAt": expiresAt.Format(time.RFC3339),
+	}); err != nil {
+		return nil, err
+	}
+	if err = enqueueEmail(ctx, tx, oldEmail, mailer.EmailChangeNotice, locale, map[string]any{
+		"NewEmail": newEmail,
+		"Link":     repo.PublicURL + "/cancel-email-change?token=" + cancelToken,
+	}); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+
+	return &pb.EmailChangeResponse{
+		Message: "A confirmation link has been sent to the new address",
+		Email:   oldEmail,
+	}, nil
+}
+
+// ConfirmEmailChange swaps in the new address of a pending change and marks
+// it verified, since the user proved they can read it.
+func (repo *UserRepository) ConfirmEmailChange(ctx context.Context, request *pb.EmailChangeTokenRequest) (_ *pb.EmailChangeResponse, err error) {
+	ctx, span := startSpan(ctx, "ConfirmEmailChange")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	var id, userID, newEmail, newKey string
+	err = tx.QueryRowContext(ctx, `
+	SELECT id, user_id, new_email, new_email_canonical
+	FROM email_change_requests
+	WHERE confirm_token_hash = $1 AND confirmed_at IS NULL AND cancelled_at IS NULL AND expires_at > NOW()
+	FOR UPDATE
+	`, hashLinkToken(request.Token)).Scan(&id, &userID, &newEmail, &newKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errInvalidEmailToken
+	}
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE users SET email = $1, email_canonical = $2, email_verified_at = NOW(), updated_at = NOW() WHERE id = $3 AND deleted_at IS NULL",
+		newEmail, newKey, userID,
+	)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil, apperrors.NotFound("user not found")
+	}
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE email_change_requests SET confirmed_at = NOW() WHERE id = $1",
+		id,
+	); err != nil {
+		return nil, mapError(err, "")
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+
+	return &pb.EmailChangeResponse{Message: "Your email address has been changed", Email: newEmail}, nil
+}
+
+// CancelEmailChange withdraws a pending change using the token sent to the
+// old address.
+func (repo *UserRepository) CancelEmailChange(ctx context.Context, request *pb.EmailChangeTokenRequest) (_ *pb.EmailChangeResponse, err error) {
+	ctx, span := startSpan(ctx, "CancelEmailChange")
+	defer func() { endSpan(span, err) }()
+
+	var oldEmail string
+	err = repo.Db.QueryRowContext(ctx, `
+	UPDATE email_change_requests SET cancelled_at = NOW()
+	WHERE cancel_token_hash = $1 AND confirmed_at IS NULL AND cancelled_at IS NULL
+	RETURNING old_email
+	`, hashLinkToken(request.Token)).Scan(&oldEmail)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errInvalidEmailToken
+	}
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+
+	return &pb.EmailChangeResponse{Message: "The email change has been cancelled", Email: oldEmail}, nil
+}
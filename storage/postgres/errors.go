@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"Auth-Service/apperrors"
+
+	"github.com/lib/pq"
+)
+
+// conflictMessages maps unique constraints to the message shown to clients.
+var conflictMessages = map[string]string{
+	"users_username_canonical_key": "username is already taken",
+	"users_email_canonical_key":    "email is already registered",
+	"reports_open_key":             "you already reported this user",
+}
+
+// mapError translates driver errors into apperrors values. notFound is the
+// message used when the query matched no rows.
+func mapError(err error, notFound string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &apperrors.Error{Kind: apperrors.KindNotFound, Message: notFound, Err: err}
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505": // unique_violation
+			msg, ok := conflictMessages[pqErr.Constraint]
+			if !ok {
+				msg = "resource already exists"
+			}
+			return &apperrors.Error{Kind: apperrors.KindConflict, Message: msg, Err: err}
+		case "23503": // foreign_key_violation
+			return &apperrors.Error{Kind: apperrors.KindNotFound, Message: "referenced user not found", Err: err}
+		case "22P02": // invalid_text_representation, e.g. a malformed UUID
+			return &apperrors.Error{Kind: apperrors.KindNotFound, Message: notFound, Err: err}
+		}
+	}
+	return apperrors.Internal(err)
+}
@@ -0,0 +1,234 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"Auth-Service/apperrors"
+	pb "Auth-Service/genproto/users"
+	"Auth-Service/notification"
+)
+
+// requestFollow files a follow request from requester to a private target
+// that requester does not follow yet, and tells the target about it. It is
+// a no-op when the request is already pending or the target is not private.
+func (repo *UserRepository) requestFollow(ctx context.Context, tx *sql.Tx, requester, target string) error {
+	var requestedAt time.Time
+	err := tx.QueryRowContext(ctx, `
+	INSERT INTO
+	  follow_requests(
+		requester_id,
+		target_id
+	  )
+	SELECT $1, $2
+	WHERE EXISTS (SELECT 1 FROM users WHERE id = $2 AND deleted_at IS NULL AND is_private)
+	  AND NOT EXISTS (SELECT 1 FROM followers WHERE follower_id = $1 AND following_id = $2)
+	ON CONFLICT (requester_id, target_id) DO NOTHING
+	RETURNING
+	  created_at
+	`, requester, target).Scan(&requestedAt)
+	switch {
+	case err == nil:
+		return repo.notify(ctx, tx, target, notification.FollowRequest, requester)
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	default:
+		return mapError(err, "user not found")
+	}
+}
+
+// ListFollowRequests lists the requests waiting for req.UserId to approve,
+// oldest first. Requests from deleted users are left out.
+func (repo *UserRepository) ListFollowRequests(ctx context.Context, req *pb.FollowRequestsRequest) (_ *pb.FollowRequestsResponse, err error) {
+	ctx, span := startSpan(ctx, "ListFollowRequests")
+	defer func() { endSpan(span, err) }()
+	page, limit := pageBounds(req.Page, req.Limit)
+
+	rows, err := repo.Db.QueryContext(ctx, `
+	SELECT
+	  u.id,
+	  u.username,
+	  u.full_name,
+	  r.created_at,
+	  COUNT(*) OVER ()
+	FROM
+	  follow_requests r
+	JOIN
+	  users u ON u.id = r.requester_id
+	WHERE
+	  r.target_id = $1 AND u.deleted_at IS NULL
+	ORDER BY
+	  r.created_at, u.id
+	OFFSET $2
+	LIMIT $3
+	`, req.UserId, (page-1)*limit, limit)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer rows.Close()
+
+	response := &pb.FollowRequestsResponse{Requests: []*pb.PendingFollowRequest{}, Page: page, Limit: limit}
+	for rows.Next() {
+		var r pb.PendingFollowRequest
+		var requestedAt time.Time
+		if err = rows.Scan(&r.RequesterId, &r.UserName, &r.FullName, &requestedAt, &response.Total); err != nil {
+			return nil, mapError(err, "")
+		}
+		r.RequestedAt = requestedAt.UTC().Format(time.RFC3339)
+		response.Requests = append(response.Requests, &r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err, "")
+	}
+	return response, nil
+}
+
+// ApproveFollowRequest turns a pending request into a follow and tells the
+// requester. Approving a request from someone who already follows the user
+// succeeds without changing anything else; a request from a deleted account
+// is dropped and reported as not found.
+func (repo *UserRepository) ApproveFollowRequest(ctx context.Context, req *pb.FollowRequestAction) (_ *pb.FollowRequestActionResponse, err error) {
+	ctx, span := startSpan(ctx, "ApproveFollowRequest")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	if err = deleteFollowRequest(ctx, tx, req.RequesterId, req.UserId); err != nil {
+		return nil, err
+	}
+
+	var followedAt time.Time
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO
+	  followers(
+		follower_id,
+		following_id
+	  )
+	SELECT $1, $2
+	WHERE EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)
+	ON CONFLICT (follower_id, following_id) DO NOTHING
+	RETURNING
+	  followed_at
+	`, req.RequesterId, req.UserId).Scan(&followedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Nothing was inserted: either the requester already follows the
+		// user, which makes approval a no-op, or the requester's account
+		// is gone. Either way the request itself is settled and its
+		// deletion is kept.
+		err = tx.QueryRowContext(ctx,
+			"SELECT followed_at FROM followers WHERE follower_id = $1 AND following_id = $2",
+			req.RequesterId, req.UserId,
+		).Scan(&followedAt)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, mapError(err, "")
+		}
+		gone := err != nil
+		if err = tx.Commit(); err != nil {
+			return nil, mapError(err, "")
+		}
+		if gone {
+			return nil, apperrors.NotFound("user not found")
+		}
+	case err != nil:
+		return nil, mapError(err, "")
+	default:
+		if err = adjustFollowCounts(ctx, tx, req.RequesterId, req.UserId, 1); err != nil {
+			return nil, err
+		}
+		if err = repo.notify(ctx, tx, req.RequesterId, notification.FollowAccepted, req.UserId); err != nil {
+			return nil, err
+		}
+		if err = tx.Commit(); err != nil {
+			return nil, mapError(err, "")
+		}
+	}
+
+	return &pb.FollowRequestActionResponse{
+		RequesterId: req.RequesterId,
+		Approved:    true,
+		FollowedAt:  followedAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// RejectFollowRequest drops a pending request. The requester is not told.
+func (repo *UserRepository) RejectFollowRequest(ctx context.Context, req *pb.FollowRequestAction) (_ *pb.FollowRequestActionResponse, err error) {
+	ctx, span := startSpan(ctx, "RejectFollowRequest")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	if err = deleteFollowRequest(ctx, tx, req.RequesterId, req.UserId); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+	return &pb.FollowRequestActionResponse{RequesterId: req.RequesterId}, nil
+}
+
+func deleteFollowRequest(ctx context.Context, tx *sql.Tx, requester, target string) error {
+	var id string
+	err := tx.QueryRowContext(ctx,
+		"DELETE FROM follow_requests WHERE requester_id = $1 AND target_id = $2 RETURNING requester_id",
+		requester, target,
+	).Scan(&id)
+	return mapError(err, "follow request not found")
+}
+
+// approveAllFollowRequests accepts every request pending for target, as
+// happens when the account stops being private.
+func (repo *UserRepository) approveAllFollowRequests(ctx context.Context, tx *sql.Tx, target string) error {
+	rows, err := tx.QueryContext(ctx, `
+	WITH approved AS (
+	  DELETE FROM follow_requests WHERE target_id = $1 RETURNING requester_id
+	)
+	INSERT INTO
+	  followers(
+		follower_id,
+		following_id
+	  )
+	SELECT a.requester_id, $1
+	FROM approved a
+	JOIN users u ON u.id = a.requester_id AND u.deleted_at IS NULL
+	ON CONFLICT (follower_id, following_id) DO NOTHING
+	RETURNING
+	  follower_id
+	`, target)
+	if err != nil {
+		return mapError(err, "")
+	}
+	var requesters []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return mapError(err, "")
+		}
+		requesters = append(requesters, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return mapError(err, "")
+	}
+
+	for _, requester := range requesters {
+		if err = adjustFollowCounts(ctx, tx, requester, target, 1); err != nil {
+			return err
+		}
+		if err = repo.notify(ctx, tx, requester, notification.FollowAccepted, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
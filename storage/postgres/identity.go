@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"Auth-Service/canonical"
+
+	"github.com/lib/pq"
+)
+
+// IdentityCollisions lists users whose usernames or emails share a
+// canonical form. All but one user of each group must be renamed or merged
+// before identities can be canonicalized.
+type IdentityCollisions []IdentityCollision
+
+// IdentityCollision is one canonical form held by several users.
+type IdentityCollision struct {
+	Kind      string // "username" or "email"
+	Canonical string
+	UserIDs   []string // oldest first
+}
+
+func (c IdentityCollisions) Error() string {
+	var b strings.Builder
+	b.WriteString("canonical username/email collisions must be resolved first:")
+	for _, collision := range c {
+		fmt.Fprintf(&b, "\n%s %q: %s", collision.Kind, collision.Canonical, strings.Join(collision.UserIDs, ", "))
+	}
+	return b.String()
+}
+
+// RecanonicalizeIdentities fills in username_canonical and email_canonical
+// from package canonical for every user whose stored forms are missing or
+// stale, then makes both columns NOT NULL and drops the exact-match unique
+// constraints they replace. The table is locked for the run and nothing is
+// written while any two users collide; the returned IdentityCollisions
+// then lists every collision at once. It returns how many users were
+// updated and is safe to run again.
+func (repo *UserRepository) RecanonicalizeIdentities(ctx context.Context) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "RecanonicalizeIdentities")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return 0, mapError(err, "")
+	}
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, username, email, username_canonical, email_canonical FROM users ORDER BY created_at, id")
+	if err != nil {
+		return 0, mapError(err, "")
+	}
+	type identity struct{ id, username, email string }
+	var stale []identity
+	var forms []IdentityCollision
+	holders := make(map[[2]string]int)
+	hold := func(kind, form, id string) {
+		key := [2]string{kind, form}
+		i, ok := holders[key]
+		if !ok {
+			i = len(forms)
+			holders[key] = i
+			forms = append(forms, IdentityCollision{Kind: kind, Canonical: form})
+		}
+		forms[i].UserIDs = append(forms[i].UserIDs, id)
+	}
+	for rows.Next() {
+		var id, username, email string
+		var storedUsername, storedEmail sql.NullString
+		if err = rows.Scan(&id, &username, &email, &storedUsername, &storedEmail); err != nil {
+			rows.Close()
+			return 0, mapError(err, "")
+		}
+		u := identity{id, canonical.Username(username), canonical.Email(email)}
+		hold("username", u.username, id)
+		hold("email", u.email, id)
+		if storedUsername.String != u.username || storedEmail.String != u.email {
+			stale = append(stale, u)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, mapError(err, "")
+	}
+	var collisions IdentityCollisions
+	for _, form := range forms {
+		if len(form.UserIDs) > 1 {
+			collisions = append(collisions, form)
+		}
+	}
+	if len(collisions) > 0 {
+		return 0, collisions
+	}
+
+	// Stale forms may still be held by other rows, so free them all first.
+	if len(stale) > 0 {
+		ids := make([]string, len(stale))
+		for i, u := range stale {
+			ids[i] = u.id
+		}
+		if _, err = tx.ExecContext(ctx,
+			"UPDATE users SET username_canonical = NULL, email_canonical = NULL WHERE id = ANY($1::uuid[])",
+			pq.Array(ids),
+		); err != nil {
+			return 0, mapError(err, "")
+		}
+	}
+	for _, u := range stale {
+		if _, err = tx.ExecContext(ctx,
+			"UPDATE users SET username_canonical = $2, email_canonical = $3 WHERE id = $1",
+			u.id, u.username, u.email,
+		); err != nil {
+			return 0, fmt.Errorf("user %s: %w", u.id, mapError(err, ""))
+		}
+	}
+	if _, err = tx.ExecContext(ctx, `
+	ALTER TABLE users
+	  ALTER COLUMN username_canonical SET NOT NULL,
+	  ALTER COLUMN email_canonical SET NOT NULL,
+	  DROP CONSTRAINT IF EXISTS users_username_key,
+	  DROP CONSTRAINT IF EXISTS users_email_key
+	`); err != nil {
+		return 0, mapError(err, "")
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, mapError(err, "")
+	}
+	return int64(len(stale)), nil
+}
@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"Auth-Service/apperrors"
+	pb "Auth-Service/genproto/users"
+	"Auth-Service/mailer"
+	"Auth-Service/moderation"
+)
+
+const reportColumns = `
+	id,
+	reporter_id,
+	reported_id,
+	reason,
+	details,
+	status,
+	COALESCE(moderator_id::text, ''),
+	COALESCE(action, ''),
+	COALESCE(note, ''),
+	created_at,
+	claimed_at,
+	resolved_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReport(row rowScanner, extra ...any) (*pb.Report, error) {
+	var r pb.Report
+	var createdAt time.Time
+	var claimedAt, resolvedAt sql.NullTime
+	dest := append([]any{&r.Id, &r.ReporterId, &r.ReportedId, &r.Reason, &r.Details, &r.Status,
+		&r.ModeratorId, &r.Action, &r.Note, &createdAt, &claimedAt, &resolvedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	r.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	if claimedAt.Valid {
+		r.ClaimedAt = claimedAt.Time.UTC().Format(time.RFC3339)
+	}
+	if resolvedAt.Valid {
+		r.ResolvedAt = resolvedAt.Time.UTC().Format(time.RFC3339)
+	}
+	return &r, nil
+}
+
+// requireModerator returns Forbidden unless userID is a moderator.
+func requireModerator(ctx context.Context, q queryRower, userID string) error {
+	var ok bool
+	err := q.QueryRowContext(ctx,
+		"SELECT role = 'moderator' FROM users WHERE id = $1 AND deleted_at IS NULL",
+		userID,
+	).Scan(&ok)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return mapError(err, "")
+	}
+	if !ok {
+		return apperrors.Forbidden("only moderators can do this")
+	}
+	return nil
+}
+
+// ReportUser files a report about req.UserId for moderators to review. A
+// reporter has at most one unresolved report per user.
+func (repo *UserRepository) ReportUser(ctx context.Context, req *pb.ReportUserRequest) (_ *pb.Report, err error) {
+	ctx, span := startSpan(ctx, "ReportUser")
+	defer func() { endSpan(span, err) }()
+
+	report, err := scanReport(repo.Db.QueryRowContext(ctx, `
+	INSERT INTO
+	  reports(
+		reporter_id,
+		reported_id,
+		reason,
+		details
+	  )
+	SELECT $1, $2, $3, $4
+	WHERE EXISTS (SELECT 1 FROM users WHERE id = $2 AND deleted_at IS NULL)
+	RETURNING`+reportColumns,
+		req.ReporterId, req.UserId, req.Reason, req.Details))
+	if err != nil {
+		return nil, mapError(err, "user not found")
+	}
+	return report, nil
+}
+
+// ListReports lists reports oldest first, the unresolved ones unless
+// req.Status asks for others.
+func (repo *UserRepository) ListReports(ctx context.Context, req *pb.ListReportsRequest) (_ *pb.ListReportsResponse, err error) {
+	ctx, span := startSpan(ctx, "ListReports")
+	defer func() { endSpan(span, err) }()
+
+	if err = requireModerator(ctx, repo.Db, req.ModeratorId); err != nil {
+		return nil, err
+	}
+	page, limit := pageBounds(req.Page, req.Limit)
+
+	rows, err := repo.Db.QueryContext(ctx, `
+	SELECT`+reportColumns+`,
+	  COUNT(*) OVER ()
+	FROM
+	  reports
+	WHERE
+	  CASE WHEN $1 = '' THEN status <> 'resolved' ELSE status = $1 END
+	ORDER BY
+	  created_at, id
+	OFFSET $2
+	LIMIT $3
+	`, req.Status, (page-1)*limit, limit)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer rows.Close()
+
+	response := &pb.ListReportsResponse{Reports: []*pb.Report{}, Page: page, Limit: limit}
+	for rows.Next() {
+		report, err := scanReport(rows, &response.Total)
+		if err != nil {
+			return nil, mapError(err, "")
+		}
+		response.Reports = append(response.Reports, report)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err, "")
+	}
+	return response, nil
+}
+
+// ClaimReport assigns an open report to the moderator, so others leave it
+// alone. Claiming one's own report again is a no-op.
+func (repo *UserRepository) ClaimReport(ctx context.Context, req *pb.ClaimReportRequest) (_ *pb.Report, err error) {
+	ctx, span := startSpan(ctx, "ClaimReport")
+	defer func() { endSpan(span, err) }()
+
+	if err = requireModerator(ctx, repo.Db, req.ModeratorId); err != nil {
+		return nil, err
+	}
+
+	report, err := scanReport(repo.Db.QueryRowContext(ctx, `
+	UPDATE reports
+	SET status = 'claimed', moderator_id = $1, claimed_at = COALESCE(claimed_at, NOW())
+	WHERE id = $2 AND (status = 'open' OR (status = 'claimed' AND moderator_id = $1))
+	RETURNING`+reportColumns,
+		req.ModeratorId, req.ReportId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repo.reportUnavailable(ctx, req.ReportId)
+	}
+	if err != nil {
+		return nil, mapError(err, "report not found")
+	}
+	return report, nil
+}
+
+// ResolveReport closes a report that is open or claimed by the moderator and
+// applies the action to the reported user; suspending or banning revokes
+// the user's tokens. The user is told by email of
+// anything but a dismissal, and the decision goes to the audit log.
+func (repo *UserRepository) ResolveReport(ctx context.Context, req *pb.ResolveReportRequest) (_ *pb.Report, err error) {
+	ctx, span := startSpan(ctx, "ResolveReport")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	if err = requireModerator(ctx, tx, req.ModeratorId); err != nil {
+		return nil, err
+	}
+
+	report, err := scanReport(tx.QueryRowContext(ctx, `
+	UPDATE reports
+	SET status = 'resolved', moderator_id = $1, action = $3, note = $4,
+	    claimed_at = COALESCE(claimed_at, NOW()), resolved_at = NOW()
+	WHERE id = $2 AND (status = 'open' OR (status = 'claimed' AND moderator_id = $1))
+	RETURNING`+reportColumns,
+		req.ModeratorId, req.ReportId, req.Action, req.Note))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repo.reportUnavailable(ctx, req.ReportId)
+	}
+	if err != nil {
+		return nil, mapError(err, "report not found")
+	}
+
+	details := map[string]any{"report_id": report.Id, "reason": report.Reason, "note": req.Note}
+	var cutoff time.Time
+	if req.Action != moderation.ActionDismiss {
+		var email, locale string
+		var until, notBefore sql.NullTime
+		err = tx.QueryRowContext(ctx, `
+		UPDATE users
+		SET suspended_until = CASE WHEN $2 = 'suspend'
+		        THEN GREATEST(COALESCE(suspended_until, NOW()), NOW() + make_interval(secs => $3))
+		        ELSE suspended_until END,
+		    banned_at = CASE WHEN $2 = 'ban' THEN COALESCE(banned_at, NOW()) ELSE banned_at END,
+		    tokens_not_before = CASE WHEN $2 IN ('suspend', 'ban') THEN NOW() ELSE tokens_not_before END
+		WHERE id = $1
+		RETURNING email, locale, suspended_until, tokens_not_before
+		`, report.ReportedId, req.Action, req.SuspendSeconds).Scan(&email, &locale, &until, &notBefore)
+		if err != nil {
+			return nil, mapError(err, "user not found")
+		}
+
+		data := map[string]any{"Action": req.Action, "Note": req.Note}
+		if req.Action == moderation.ActionSuspend {
+			data["Until"] = until.Time.UTC().Format(time.RFC3339)
+			details["suspended_until"] = data["Until"]
+		}
+		if err = enqueueEmail(ctx, tx, email, mailer.AccountModerated, locale, data); err != nil {
+			return nil, err
+		}
+		cutoff = notBefore.Time
+	}
+	if err = audit(ctx, tx, req.ModeratorId, "moderation."+req.Action, report.ReportedId, details); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+	if !cutoff.IsZero() {
+		repo.rememberCutoff(ctx, report.ReportedId, cutoff)
+	}
+	return report, nil
+}
+
+// reportUnavailable explains why a report could not be claimed or resolved.
+func (repo *UserRepository) reportUnavailable(ctx context.Context, reportID string) error {
+	var status string
+	err := repo.Db.QueryRowContext(ctx, "SELECT status FROM reports WHERE id = $1", reportID).Scan(&status)
+	if err != nil {
+		return mapError(err, "report not found")
+	}
+	if status == moderation.StatusResolved {
+		return apperrors.Conflict("report is already resolved")
+	}
+	return apperrors.Conflict("report is claimed by another moderator")
+}
@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"Auth-Service/apperrors"
+	pb "Auth-Service/genproto/users"
+	"Auth-Service/notification"
+
+	"github.com/lib/pq"
+)
+
+const defaultPageSize = 10
+
+// pageBounds fills in the defaults for an unset 1-based page and limit.
+func pageBounds(page, limit int32) (int32, int32) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if page <= 0 {
+		page = 1
+	}
+	return page, limit
+}
+
+// notify tells userID about an event caused by actorID, in-app and/or by
+// email as the user's preference for the event type says. It runs within
+// the transaction of the change that caused the event. Notification emails
+// use the event type as their template name and get the actor's username.
+func (repo *UserRepository) notify(ctx context.Context, tx *sql.Tx, userID, eventType, actorID string) error {
+	def := notification.Default(eventType)
+	var email, locale, actorUsername string
+	var pref notification.Preference
+	err := tx.QueryRowContext(ctx, `
+	SELECT
+		u.email,
+		u.locale,
+		COALESCE(p.in_app, $3),
+		COALESCE(p.email, $4),
+		COALESCE((SELECT username FROM users WHERE id = $5), '')
+	FROM
+		users u
+	LEFT JOIN
+		notification_preferences p ON p.user_id = u.id AND p.type = $2
+	WHERE
+		u.id = $1 AND u.deleted_at IS NULL
+	`, userID, eventType, def.InApp, def.Email, actorID).Scan(&email, &locale, &pref.InApp, &pref.Email, &actorUsername)
+	if err != nil {
+		return mapError(err, "user not found")
+	}
+
+	if pref.InApp {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO notifications (user_id, type, actor_id) VALUES ($1, $2, $3)",
+			userID, eventType, actorID,
+		); err != nil {
+			return mapError(err, "")
+		}
+	}
+	if pref.Email {
+		return enqueueEmail(ctx, tx, email, eventType, locale, map[string]any{
+			"ActorUsername": actorUsername,
+			"Link":          repo.PublicURL + "/users/by-username/" + actorUsername,
+		})
+	}
+	return nil
+}
+
+// ListNotifications returns the user's notifications, newest first, with the
+// number of unread ones.
+func (repo *UserRepository) ListNotifications(ctx context.Context, request *pb.ListNotificationsRequest) (_ *pb.ListNotificationsResponse, err error) {
+	ctx, span := startSpan(ctx, "ListNotifications")
+	defer func() { endSpan(span, err) }()
+	page, limit := pageBounds(request.Page, request.Limit)
+
+	rows, err := repo.Db.QueryContext(ctx, `
+	SELECT
+		n.id,
+		n.type,
+		COALESCE(n.actor_id::text, ''),
+		COALESCE(a.username, ''),
+		n.created_at,
+		n.read_at
+	FROM
+		notifications n
+	LEFT JOIN
+		users a ON a.id = n.actor_id AND a.deleted_at IS NULL
+	WHERE
+		n.user_id = $1 AND (NOT $2 OR n.read_at IS NULL)
+	ORDER BY
+		n.created_at DESC, n.id
+	OFFSET $3
+	LIMIT $4
+	`, request.UserId, request.UnreadOnly, (page-1)*limit, limit)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer rows.Close()
+
+	response := &pb.ListNotificationsResponse{Notifications: []*pb.Notification{}, Page: page, Limit: limit}
+	for rows.Next() {
+		var n pb.Notification
+		var createdAt time.Time
+		var readAt sql.NullTime
+		if err = rows.Scan(&n.Id, &n.Type, &n.ActorId, &n.ActorUsername, &createdAt, &readAt); err != nil {
+			return nil, mapError(err, "")
+		}
+		n.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		if readAt.Valid {
+			n.ReadAt = readAt.Time.UTC().Format(time.RFC3339)
+		}
+		response.Notifications = append(response.Notifications, &n)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err, "")
+	}
+
+	if response.UnreadCount, err = repo.unreadNotifications(ctx, request.UserId); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// MarkNotificationsRead marks the given notifications of the user as read,
+// or all of them when no ids are given. Ids of other users' notifications
+// are ignored.
+func (repo *UserRepository) MarkNotificationsRead(ctx context.Context, request *pb.MarkNotificationsReadRequest) (_ *pb.MarkNotificationsReadResponse, err error) {
+	ctx, span := startSpan(ctx, "MarkNotificationsRead")
+	defer func() { endSpan(span, err) }()
+
+	result, err := repo.Db.ExecContext(ctx, `
+	UPDATE notifications SET read_at = NOW()
+	WHERE user_id = $1 AND read_at IS NULL AND (cardinality($2::uuid[]) = 0 OR id = ANY($2::uuid[]))
+	`, request.UserId, pq.Array(request.Ids))
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	marked, err := result.RowsAffected()
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+
+	unread, err := repo.unreadNotifications(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.MarkNotificationsReadResponse{Marked: int32(marked), UnreadCount: unread}, nil
+}
+
+func (repo *UserRepository) unreadNotifications(ctx context.Context, userID string) (int32, error) {
+	var unread int32
+	err := repo.Db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL",
+		userID,
+	).Scan(&unread)
+	return unread, mapError(err, "")
+}
+
+// GetNotificationPreferences returns the user's preference for every event
+// type, defaults included.
+func (repo *UserRepository) GetNotificationPreferences(ctx context.Context, request *pb.NotificationPreferencesRequest) (_ *pb.NotificationPreferencesResponse, err error) {
+	ctx, span := startSpan(ctx, "GetNotificationPreferences")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := repo.Db.QueryContext(ctx,
+		"SELECT type, in_app, email FROM notification_preferences WHERE user_id = $1",
+		request.UserId,
+	)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer rows.Close()
+
+	stored := make(map[string]notification.Preference)
+	for rows.Next() {
+		var t string
+		var p notification.Preference
+		if err = rows.Scan(&t, &p.InApp, &p.Email); err != nil {
+			return nil, mapError(err, "")
+		}
+		stored[t] = p
+	}
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err, "")
+	}
+
+	response := &pb.NotificationPreferencesResponse{}
+	for _, t := range notification.Types() {
+		p, ok := stored[t]
+		if !ok {
+			p = notification.Default(t)
+		}
+		response.Preferences = append(response.Preferences, &pb.NotificationPreference{Type: t, InApp: p.InApp, Email: p.Email})
+	}
+	return response, nil
+}
+
+// UpdateNotificationPreferences stores the given preferences; event types
+// left out keep their current setting.
+func (repo *UserRepository) UpdateNotificationPreferences(ctx context.Context, request *pb.UpdateNotificationPreferencesRequest) (_ *pb.NotificationPreferencesResponse, err error) {
+	ctx, span := startSpan(ctx, "UpdateNotificationPreferences")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	for _, p := range request.Preferences {
+		if _, err = tx.ExecContext(ctx, `
+		INSERT INTO notification_preferences (user_id, type, in_app, email)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, type) DO UPDATE SET in_app = EXCLUDED.in_app, email = EXCLUDED.email, updated_at = NOW()
+		`, request.UserId, p.Type, p.InApp, p.Email); err != nil {
+			return nil, mapError(err, "user not found")
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+
+	return repo.GetNotificationPreferences(ctx, &pb.NotificationPreferencesRequest{UserId: request.UserId})
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"Auth-Service/apperrors"
+	"Auth-Service/mailer"
+)
+
+// enqueueEmail queues a message in the outbox within tx, so it is sent if
+// and only if the change that triggered it commits.
+func enqueueEmail(ctx context.Context, tx *sql.Tx, to, template, locale string, data map[string]any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO email_outbox (recipient, template, locale, data) VALUES ($1, $2, $3, $4)",
+		to, template, locale, payload,
+	)
+	return mapError(err, "")
+}
+
+// ClaimEmails implements mailer.Store. SKIP LOCKED lets several instances
+// drain the outbox without delivering a message twice.
+func (repo *UserRepository) ClaimEmails(ctx context.Context, limit int, lease time.Duration) (_ []mailer.Envelope, err error) {
+	ctx, span := startSpan(ctx, "ClaimEmails")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := repo.Db.QueryContext(ctx, `
+	UPDATE email_outbox o
+	SET attempts = o.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+	FROM (
+		SELECT id FROM email_outbox
+		WHERE status = 'pending' AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	) due
+	WHERE o.id = due.id
+	RETURNING o.id, o.recipient, o.template, o.locale, o.data, o.attempts
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer rows.Close()
+
+	var batch []mailer.Envelope
+	for rows.Next() {
+		var env mailer.Envelope
+		var data []byte
+		if err = rows.Scan(&env.ID, &env.To, &env.Template, &env.Locale, &data, &env.Attempts); err != nil {
+			return nil, mapError(err, "")
+		}
+		if err = json.Unmarshal(data, &env.Data); err != nil {
+			return nil, apperrors.Internal(err)
+		}
+		batch = append(batch, env)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err, "")
+	}
+	return batch, nil
+}
+
+// MarkEmailSent implements mailer.Store.
+func (repo *UserRepository) MarkEmailSent(ctx context.Context, id int64) (err error) {
+	ctx, span := startSpan(ctx, "MarkEmailSent")
+	defer func() { endSpan(span, err) }()
+
+	_, err = repo.Db.ExecContext(ctx,
+		"UPDATE email_outbox SET status = 'sent', sent_at = NOW(), last_error = NULL WHERE id = $1",
+		id,
+	)
+	return mapError(err, "")
+}
+
+// MarkEmailFailed implements mailer.Store.
+func (repo *UserRepository) MarkEmailFailed(ctx context.Context, id int64, reason string, retryAt time.Time) (err error) {
+	ctx, span := startSpan(ctx, "MarkEmailFailed")
+	defer func() { endSpan(span, err) }()
+
+	if retryAt.IsZero() {
+		_, err = repo.Db.ExecContext(ctx,
+			"UPDATE email_outbox SET status = 'failed', last_error = $2 WHERE id = $1",
+			id, reason,
+		)
+	} else {
+		_, err = repo.Db.ExecContext(ctx,
+			"UPDATE email_outbox SET next_attempt_at = $3, last_error = $2 WHERE id = $1",
+			id, reason, retryAt,
+		)
+	}
+	return mapError(err, "")
+}
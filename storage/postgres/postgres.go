@@ -7,8 +7,7 @@ import (
 	_ "github.com/lib/pq"
 )
 
-func ConnectionDb() (*sql.DB, error) {
-	cnf := config.Load()
-	conDb := fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s sslmode=disable", cnf.PostgresHost, cnf.PostgresPort, cnf.PostgresUser, cnf.PostgresDatabase, cnf.PostgresPassword)
+func ConnectionDb(cnf config.PostgresConfig) (*sql.DB, error) {
+	conDb := fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s sslmode=disable", cnf.Host, cnf.Port, cnf.User, cnf.Database, cnf.Password)
 	return sql.Open("postgres", conDb)
 }
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"Auth-Service/canonical"
+	pb "Auth-Service/genproto/users"
+	"Auth-Service/search"
+)
+
+// userMatches selects the users matching a search: $1 is the canonical
+// query, $2 its LIKE prefix pattern and $3 the viewer, if any. Users who
+// blocked the viewer are left out, and private accounts the viewer does not
+// follow show no countries, so they cannot be found by them either.
+const userMatches = `
+WITH matches AS (
+  SELECT
+    u.id,
+    u.username,
+    u.full_name,
+    u.username_canonical,
+    u.created_at,
+    CASE WHEN NOT u.is_private OR u.id = NULLIF($3, '')::uuid
+           OR EXISTS (SELECT 1 FROM followers f WHERE f.follower_id = NULLIF($3, '')::uuid AND f.following_id = u.id)
+         THEN COALESCE(u.countries_visited, 0) ELSE 0 END AS countries_visited,
+    CASE WHEN $1 = '' THEN 0
+         ELSE GREATEST(similarity(u.username_canonical, $1), word_similarity($1, lower(u.full_name)))
+              + CASE WHEN u.username_canonical LIKE $2 OR lower(u.full_name) LIKE $2 THEN 1 ELSE 0 END
+    END AS score
+  FROM
+    users u
+  WHERE
+    u.deleted_at IS NULL
+    AND ($1 = '' OR u.username_canonical LIKE $2 OR lower(u.full_name) LIKE $2
+         OR u.username_canonical % $1 OR $1 <% lower(u.full_name))
+    AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = u.id AND b.blocked_id = NULLIF($3, '')::uuid)
+)`
+
+// userSortKeys gives, for every sort order, the column of matches it sorts
+// by, the type a cursor key is cast back to and whether it sorts
+// descending. Ties are broken by id in the same direction.
+var userSortKeys = map[string]struct {
+	column string
+	cast   string
+	desc   bool
+}{
+	search.SortUsername:         {"username_canonical", "text", false},
+	search.SortNewest:           {"created_at", "timestamptz", true},
+	search.SortCountriesVisited: {"countries_visited", "integer", true},
+	search.SortRelevance:        {"score", "float8", true},
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetUsers searches users by a prefix of or resemblance to their username
+// or full name, pages through the results with keyset cursors and counts
+// every match. Validation has made sure the cursor fits the sort order.
+func (repo *UserRepository) GetUsers(ctx context.Context, request *pb.GetUsersRequest) (_ *pb.GetUsersResponse, err error) {
+	ctx, span := startSpan(ctx, "GetUsers")
+	defer func() { endSpan(span, err) }()
+	_, limit := pageBounds(0, request.Limit)
+
+	query := canonical.Username(request.Query)
+	prefix := likeEscaper.Replace(query) + "%"
+	sort := request.Sort
+	if sort == "" {
+		sort = search.DefaultSort(query)
+	}
+	key := userSortKeys[sort]
+	var after search.Cursor
+	if request.Cursor != "" {
+		if after, err = search.DecodeCursor(request.Cursor); err != nil {
+			return nil, mapError(err, "")
+		}
+	}
+
+	response := &pb.GetUsersResponse{Users: []*pb.Users{}, Limit: limit}
+	err = repo.Db.QueryRowContext(ctx,
+		userMatches+" SELECT COUNT(*) FROM matches WHERE countries_visited >= $4",
+		query, prefix, request.ViewerId, request.MinCountriesVisited,
+	).Scan(&response.Total)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+
+	direction, comparison := "", ">"
+	if key.desc {
+		direction, comparison = " DESC", "<"
+	}
+	rows, err := repo.Db.QueryContext(ctx, userMatches+fmt.Sprintf(`
+	SELECT
+	  id,
+	  username,
+	  full_name,
+	  countries_visited,
+	  %[1]s::text
+	FROM
+	  matches
+	WHERE
+	  countries_visited >= $4
+	  AND ($5 = '' OR (%[1]s, id) %[3]s (NULLIF($6, '')::%[2]s, NULLIF($5, '')::uuid))
+	ORDER BY
+	  %[1]s%[4]s, id%[4]s
+	OFFSET $7
+	LIMIT $8
+	`, key.column, key.cast, comparison, direction),
+		query, prefix, request.ViewerId, request.MinCountriesVisited,
+		after.ID, after.Key, request.Offset, limit+1)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer rows.Close()
+
+	var lastKey string
+	for rows.Next() {
+		var user pb.Users
+		var sortKey string
+		if err = rows.Scan(&user.Id, &user.Username, &user.FullName, &user.CountriesVisited, &sortKey); err != nil {
+			return nil, mapError(err, "")
+		}
+		if len(response.Users) == int(limit) {
+			// The extra row only tells that there is a next page.
+			response.NextCursor = search.Cursor{Sort: sort, Key: lastKey, ID: response.Users[limit-1].Id}.Encode()
+			break
+		}
+		lastKey = sortKey
+		response.Users = append(response.Users, &user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err, "")
+	}
+	return response, nil
+}
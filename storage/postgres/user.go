@@ -1,44 +1,77 @@
 package postgres
 
 import (
+	"Auth-Service/apperrors"
+	"Auth-Service/canonical"
+	"Auth-Service/config"
 	pb "Auth-Service/genproto/users"
-	storage "Auth-Service/help"
+	"Auth-Service/mailer"
+	"Auth-Service/notification"
+	"Auth-Service/password"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"net/smtp"
 	"time"
 )
 
 type UserRepository struct {
-	Db *sql.DB
+	Db             *sql.DB
+	Username       config.UsernameConfig
+	PublicURL      string
+	ResetTTL       time.Duration
+	EmailChangeTTL time.Duration
+
+	// TokenCutoffs, when set, is updated whenever tokens are revoked.
+	TokenCutoffs CutoffCache
+	// UserCache, when set, is told about users whose summary changed.
+	UserCache UserCache
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
+func NewUserRepository(db *sql.DB, cfg *config.Config) *UserRepository {
 	return &UserRepository{
-		Db: db,
+		Db:             db,
+		Username:       cfg.Username,
+		PublicURL:      cfg.PublicURL,
+		ResetTTL:       cfg.Password.ResetTTL,
+		EmailChangeTTL: cfg.EmailChangeTTL,
 	}
 }
 
-func (repo *UserRepository) Register(ctx context.Context, request *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+func (repo *UserRepository) Register(ctx context.Context, request *pb.RegisterRequest) (_ *pb.RegisterResponse, err error) {
+	ctx, span := startSpan(ctx, "Register")
+	defer func() { endSpan(span, err) }()
 	if repo.Db == nil {
-		return nil, fmt.Errorf("database connection is not initialized")
+		return nil, apperrors.Internal(fmt.Errorf("database connection is not initialized"))
 	}
 
+	username, email := canonical.Display(request.Username), canonical.Display(request.Email)
+
+	// Usernames recently given up by someone else are on hold and are
+	// treated as taken.
 	var id, createdAt string
-	err := repo.Db.QueryRowContext(ctx,
-		`INSERT INTO users (username, email, password, full_name) 
-         VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
-		request.Username, request.Email, request.Password, request.FullName,
+	err = repo.Db.QueryRowContext(ctx,
+		`INSERT INTO users (username, username_canonical, email, email_canonical, password, full_name) 
+         SELECT $1, $2, $3, $4, $5, $6
+         WHERE NOT EXISTS (SELECT 1 FROM username_history WHERE username_canonical = $2 AND hold_until > NOW())
+         RETURNING id, created_at`,
+		username, canonical.Username(username), email, canonical.Email(email), request.Password, request.FullName,
 	).Scan(&id, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperrors.Conflict(conflictMessages["users_username_canonical_key"])
+	}
 	if err != nil {
-		return nil, err
+		return nil, mapError(err, "")
 	}
 
 	response := &pb.RegisterResponse{
 		Id:        id,
-		Username:  request.Username,
-		Email:     request.Email,
+		Username:  username,
+		Email:     email,
 		FullName:  request.FullName,
 		CreatedAt: createdAt,
 	}
@@ -46,19 +79,40 @@ func (repo *UserRepository) Register(ctx context.Context, request *pb.RegisterRe
 	return response, nil
 }
 
-func (repo *UserRepository) Login(ctx context.Context, request *pb.LoginRequest) (*pb.RegisterResponse, error) {
+func (repo *UserRepository) Login(ctx context.Context, request *pb.LoginRequest) (_ *pb.RegisterResponse, err error) {
+	ctx, span := startSpan(ctx, "Login")
+	defer func() { endSpan(span, err) }()
+	identifier, column := request.Username, "username_canonical"
+	if identifier == "" {
+		identifier = request.Email
+	}
+	if canonical.IsEmail(identifier) {
+		identifier, column = canonical.Email(identifier), "email_canonical"
+	} else {
+		identifier = canonical.Username(identifier)
+	}
+
 	var loginUser pb.RegisterResponse
-	err := repo.Db.QueryRowContext(ctx,
-		"SELECT id, username, email, full_name, created_at FROM users WHERE username = $1 AND password = $2 and deleted_at = 0",
-		request.Username, request.Password,
-	).Scan(&loginUser.Id, &loginUser.Username, &loginUser.Email, &loginUser.FullName, &loginUser.CreatedAt)
+	var state accountState
+	err = repo.Db.QueryRowContext(ctx,
+		"SELECT id, username, email, full_name, created_at, deleted_at, banned_at, suspended_until FROM users WHERE "+column+" = $1 AND password = $2",
+		identifier, request.Password,
+	).Scan(&loginUser.Id, &loginUser.Username, &loginUser.Email, &loginUser.FullName, &loginUser.CreatedAt, &state.DeletedAt, &state.BannedAt, &state.SuspendedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperrors.InvalidCredentials()
+	}
 	if err != nil {
+		return nil, mapError(err, "")
+	}
+	if err = state.Err(); err != nil {
 		return nil, err
 	}
 	return &loginUser, nil
 }
 
-func (repo *UserRepository) GetUserByID(ctx context.Context, id string) (*pb.UserInfo, error) {
+func (repo *UserRepository) GetUserByID(ctx context.Context, id string) (_ *pb.UserInfo, err error) {
+	ctx, span := startSpan(ctx, "GetUserByID")
+	defer func() { endSpan(span, err) }()
 	user := &pb.UserInfo{Id: id}
 
 	query := `
@@ -72,17 +126,14 @@ func (repo *UserRepository) GetUserByID(ctx context.Context, id string) (*pb.Use
 	FROM
 		users
 	WHERE
-		id = $1 AND deleted_at = 0
+		id = $1 AND deleted_at IS NULL
 	`
 	row := repo.Db.QueryRowContext(ctx, query, id)
 
 	var bio sql.NullString
-	err := row.Scan(&user.Username, &user.Email, &user.Password, &user.FullName, &bio, &user.CountriesVisited)
+	err = row.Scan(&user.Username, &user.Email, &user.Password, &user.FullName, &bio, &user.CountriesVisited)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, err
-		}
-		return nil, err
+		return nil, mapError(err, "user not found")
 	}
 
 	if bio.Valid {
@@ -94,156 +145,328 @@ func (repo *UserRepository) GetUserByID(ctx context.Context, id string) (*pb.Use
 	return user, nil
 }
 
-func (repo *UserRepository) Profile(ctx context.Context, request *pb.ProfileRequest) (*pb.ProfileResponse, error) {
+// Profile returns the user as request.ViewerId sees it. Users who blocked
+// the viewer are not found.
+// visibleToViewer keeps out users u who blocked the viewer $2: to them
+// such users do not exist.
+const visibleToViewer = `NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = u.id AND blocked_id = NULLIF($2, '')::uuid)`
+
+// hidesDetails reports whether a private account keeps its details and
+// follow lists from a viewer: anyone but itself and its followers.
+func hidesDetails(isPrivate, self, following bool) bool {
+	return isPrivate && !self && !following
+}
+
+func (repo *UserRepository) Profile(ctx context.Context, request *pb.ProfileRequest) (_ *pb.ProfileResponse, err error) {
+	ctx, span := startSpan(ctx, "Profile")
+	defer func() { endSpan(span, err) }()
 	var user pb.ProfileResponse
 	var bio sql.NullString
-	err := repo.Db.QueryRowContext(
-		ctx,
-		"SELECT id, username, email, full_name, bio, countries_visited, created_at, updated_at FROM users WHERE id=$1 AND deleted_at = 0",
-		request.UserId,
-	).Scan(&user.Id, &user.Username, &user.Email, &user.FullName, &bio, &user.CountriesVisited, &user.CreatedAt, &user.UpdatedAt)
+	rel := &pb.Relationship{}
+	err = repo.Db.QueryRowContext(ctx, `
+	SELECT
+		u.id, u.username, u.email, u.full_name, u.bio, u.countries_visited, u.created_at, u.updated_at,
+		u.followers_count, u.following_count, u.is_private,
+		EXISTS (SELECT 1 FROM followers WHERE follower_id = NULLIF($2, '')::uuid AND following_id = u.id),
+		EXISTS (SELECT 1 FROM followers WHERE follower_id = u.id AND following_id = NULLIF($2, '')::uuid),
+		EXISTS (SELECT 1 FROM follow_requests WHERE requester_id = NULLIF($2, '')::uuid AND target_id = u.id),
+		EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = NULLIF($2, '')::uuid AND blocked_id = u.id),
+		EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = NULLIF($2, '')::uuid AND muted_id = u.id)
+	FROM users u
+	WHERE u.id = $1 AND u.deleted_at IS NULL AND `+visibleToViewer+`
+	`, request.UserId, request.ViewerId,
+	).Scan(&user.Id, &user.Username, &user.Email, &user.FullName, &bio, &user.CountriesVisited, &user.CreatedAt, &user.UpdatedAt,
+		&user.FollowersCount, &user.FollowingCount, &user.IsPrivate, &rel.Following, &rel.FollowedBy, &rel.Requested, &rel.Blocked, &rel.Muted)
 	if err != nil {
-		return nil, err
-	}
-	if !bio.Valid {
-		user.Bio = ""
+		return nil, mapError(err, "user not found")
 	}
 	user.Bio = bio.String
+	self := request.ViewerId == user.Id
+	if request.ViewerId != "" && !self {
+		user.Relationship = rel
+	}
+	if hidesDetails(user.IsPrivate, self, rel.Following) {
+		return &pb.ProfileResponse{
+			Id:             user.Id,
+			Username:       user.Username,
+			FullName:       user.FullName,
+			FollowersCount: user.FollowersCount,
+			FollowingCount: user.FollowingCount,
+			IsPrivate:      true,
+			Relationship:   user.Relationship,
+		}, nil
+	}
 	return &user, nil
 }
 
-func (repo *UserRepository) UpdateProfile(ctx context.Context, request *pb.UpdateProfileRequest) (*pb.UpdateProfileResponse, error) {
-	query := `UPDATE users 
-			  SET full_name = $1, bio = $2, countries_visited = $3, updated_at = $4 
-			  WHERE id = $5 and deleted_at = 0
-			  RETURNING id, username, email, full_name, bio, countries_visited, updated_at`
+func (repo *UserRepository) UpdateProfile(ctx context.Context, request *pb.UpdateProfileRequest) (_ *pb.UpdateProfileResponse, err error) {
+	ctx, span := startSpan(ctx, "UpdateProfile")
+	defer func() { endSpan(span, err) }()
 
-	row := repo.Db.QueryRowContext(ctx, query,
-		request.FullName, request.Bio, request.CountriesVisited, time.Now(), request.Id)
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	// Unset fields arrive as NULL and keep the stored value.
+	query := `UPDATE users 
+			  SET full_name = COALESCE($1, full_name),
+			      bio = COALESCE($2, bio),
+			      countries_visited = COALESCE($3, countries_visited),
+			      is_private = COALESCE($6, is_private),
+			      updated_at = $4 
+			  WHERE id = $5 AND deleted_at IS NULL
+			  RETURNING id, username, email, full_name, bio, COALESCE(countries_visited, 0), updated_at, is_private`
+
+	var fullName, bio sql.NullString
+	if request.FullName != nil {
+		fullName = sql.NullString{String: *request.FullName, Valid: true}
+	}
+	if request.Bio != nil {
+		bio = sql.NullString{String: *request.Bio, Valid: true}
+	}
+	var countries sql.NullInt32
+	if request.CountriesVisited != nil {
+		countries = sql.NullInt32{Int32: *request.CountriesVisited, Valid: true}
+	}
+	var isPrivate sql.NullBool
+	if request.IsPrivate != nil {
+		isPrivate = sql.NullBool{Bool: *request.IsPrivate, Valid: true}
+	}
+	row := tx.QueryRowContext(ctx, query,
+		fullName, bio, countries, time.Now(), request.Id, isPrivate)
 
 	response := &pb.UpdateProfileResponse{}
+	var storedBio sql.NullString
 
-	err := row.Scan(
+	err = row.Scan(
 		&response.Id,
 		&response.Username,
 		&response.Email,
 		&response.FullName,
-		&response.Bio,
+		&storedBio,
 		&response.CountriesVisited,
 		&response.UpdatedAt,
+		&response.IsPrivate,
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("error updating profile: %v", err)
+		return nil, mapError(err, "user not found")
+	}
+	response.Bio = storedBio.String
+
+	if request.IsPrivate != nil && !response.IsPrivate {
+		if err = repo.approveAllFollowRequests(ctx, tx, response.Id); err != nil {
+			return nil, err
+		}
 	}
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+	repo.forgetUsers(ctx, response.Id)
 
 	return response, nil
 }
 
-func (repo *UserRepository) GetUsers(ctx context.Context, request *pb.GetUsersRequest) (*pb.GetUsersResponse, error) {
-	var (
-		params = make(map[string]interface{})
-		arr    []interface{}
-	)
-	filter := ""
-	if request.Limit > 0 {
-		params["limit"] = request.Limit
-		filter += " LIMIT :limit "
+func (repo *UserRepository) DeleteUser(ctx context.Context, request *pb.DeleteUserRequest) (_ *pb.DeleteUserResponse, err error) {
+	ctx, span := startSpan(ctx, "DeleteUser")
+	defer func() { endSpan(span, err) }()
+	var notBefore time.Time
+	err = repo.Db.QueryRowContext(
+		ctx,
+		"UPDATE users SET deleted_at=CURRENT_TIMESTAMP, tokens_not_before=CURRENT_TIMESTAMP WHERE id=$1 AND deleted_at IS NULL RETURNING tokens_not_before",
+		request.Id,
+	).Scan(&notBefore)
+	if err != nil {
+		return nil, mapError(err, "user not found")
 	}
-	if request.Offset > 0 {
-		params["offset"] = request.Offset
-		filter += " OFFSET :offset "
+	repo.rememberCutoff(ctx, request.Id, notBefore)
+	repo.forgetUsers(ctx, request.Id)
+
+	return &pb.DeleteUserResponse{StatusUser: true}, nil
+}
+
+// ResetPassword queues a single-use reset link for the account owning
+// request.Email. Unknown addresses get the same response so the endpoint
+// cannot be used to discover accounts.
+func (repo *UserRepository) ResetPassword(ctx context.Context, request *pb.ResetPasswordRequest) (_ *pb.ResetPasswordResponse, err error) {
+	ctx, span := startSpan(ctx, "ResetPassword")
+	defer func() { endSpan(span, err) }()
+	response := &pb.ResetPasswordResponse{
+		Message: "If the address belongs to an account, password reset instructions have been sent to it",
 	}
 
-	query := "SELECT id, username, full_name, countries_visited FROM users WHERE deleted_at = 0"
-	query = query + filter
-	query, arr = storage.ReplaceQueryParams(query, params)
-	rows, err := repo.Db.QueryContext(ctx, query, arr...)
+	tx, err := repo.Db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err, "")
 	}
-	defer rows.Close()
-
-	var users []*pb.Users
-	for rows.Next() {
-		var user pb.Users
-		err := rows.Scan(&user.Id, &user.Username, &user.FullName, &user.CountriesVisited)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, &user)
+	defer tx.Rollback()
+
+	var userID, email, locale string
+	err = tx.QueryRowContext(ctx,
+		"SELECT id, email, locale FROM users WHERE email_canonical = $1 AND deleted_at IS NULL",
+		canonical.Email(request.Email),
+	).Scan(&userID, &email, &locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return response, nil
+	}
+	if err != nil {
+		return nil, mapError(err, "")
 	}
 
-	return &pb.GetUsersResponse{Users: users, Limit: request.Limit, Total: int32(len(users))}, nil
-}
-
-func (repo *UserRepository) DeleteUser(ctx context.Context, request *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
-	time := time.Now().Unix()
-	_, err := repo.Db.ExecContext(
-		ctx,
-		"UPDATE users SET deleted_at=$1 WHERE id=$2 AND deleted_at = 0",
-		time, request.Id,
+	token, err := newLinkToken()
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	expiresAt := time.Now().Add(repo.ResetTTL)
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+		hashLinkToken(token), userID, expiresAt,
 	)
 	if err != nil {
+		return nil, mapError(err, "")
+	}
+	if err = enqueueEmail(ctx, tx, email, mailer.PasswordReset, locale, map[string]any{
+		"Link":      repo.PublicURL + "/reset-password?token=" + token,
+		"ExpiresAt": expiresAt.Format(time.RFC3339),
+	}); err != nil {
 		return nil, err
 	}
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
 
-	return &pb.DeleteUserResponse{StatusUser: true}, nil
+	return response, nil
 }
 
-func (repo *UserRepository) ResetPassword(ctx context.Context, request *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
-	emailBody := "Click the link to reset your password: https://your-domain.com/reset-password"
+// ConfirmPasswordReset sets a new password using a token issued by
+// ResetPassword and invalidates every outstanding token of the user.
+func (repo *UserRepository) ConfirmPasswordReset(ctx context.Context, request *pb.ConfirmPasswordResetRequest) (_ *pb.ChangePasswordResponse, err error) {
+	ctx, span := startSpan(ctx, "ConfirmPasswordReset")
+	defer func() { endSpan(span, err) }()
 
-	err := SendEmail(request.Email, "Password Reset Instructions", emailBody)
+	tx, err := repo.Db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error sending reset email: %v", err)
+		return nil, mapError(err, "")
 	}
+	defer tx.Rollback()
 
-	return &pb.ResetPasswordResponse{
-		Message: "Password reset instructions sent to your email",
-	}, nil
-}
+	var userID string
+	var id password.Identity
+	err = tx.QueryRowContext(ctx, `
+	SELECT
+		u.id,
+		u.username,
+		u.email
+	FROM
+		password_reset_tokens t
+		JOIN users u ON u.id = t.user_id
+	WHERE
+		t.token_hash = $1 AND t.used_at IS NULL AND t.expires_at > NOW() AND u.deleted_at IS NULL
+	FOR UPDATE OF t
+	`, hashLinkToken(request.Token)).Scan(&userID, &id.Username, &id.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperrors.Validation(apperrors.FieldViolation{Field: "token", Description: "is invalid or has expired"})
+	}
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+
+	if err = password.Check("new_password", request.NewPassword, id); err != nil {
+		return nil, err
+	}
 
-func SendEmail(to, subject, body string) error {
-	from := "your-email@example.com"
-	password := "your-email-password"
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE users SET password = $1, updated_at = NOW() WHERE id = $2",
+		request.NewPassword, userID,
+	); err != nil {
+		return nil, mapError(err, "")
+	}
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL",
+		userID,
+	); err != nil {
+		return nil, mapError(err, "")
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+
+	return &pb.ChangePasswordResponse{MessagePassword: true}, nil
+}
 
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
+// ChangePassword replaces the password of an authenticated user after
+// checking the current one.
+func (repo *UserRepository) ChangePassword(ctx context.Context, request *pb.ChangePasswordRequest) (_ *pb.ChangePasswordResponse, err error) {
+	ctx, span := startSpan(ctx, "ChangePassword")
+	defer func() { endSpan(span, err) }()
 
-	auth := smtp.PlainAuth("", from, password, smtpHost)
+	var current string
+	var id password.Identity
+	err = repo.Db.QueryRowContext(ctx,
+		"SELECT username, email, password FROM users WHERE id = $1 AND deleted_at IS NULL",
+		request.UserId,
+	).Scan(&id.Username, &id.Email, &current)
+	if err != nil {
+		return nil, mapError(err, "user not found")
+	}
 
-	msg := []byte("To: " + to + "\r\n" +
-		"Subject: " + subject + "\r\n" +
-		"\r\n" +
-		body + "\r\n")
+	if current != request.CurrentPassword {
+		return nil, apperrors.Validation(apperrors.FieldViolation{Field: "current_password", Description: "is incorrect"})
+	}
+	if request.NewPassword == request.CurrentPassword {
+		return nil, apperrors.Validation(apperrors.FieldViolation{Field: "new_password", Description: "must differ from the current password"})
+	}
+	if err = password.Check("new_password", request.NewPassword, id); err != nil {
+		return nil, err
+	}
 
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{to}, msg)
+	_, err = repo.Db.ExecContext(ctx,
+		"UPDATE users SET password = $1, updated_at = NOW() WHERE id = $2 AND deleted_at IS NULL",
+		request.NewPassword, request.UserId,
+	)
 	if err != nil {
-		return fmt.Errorf("error sending email: %v", err)
+		return nil, mapError(err, "user not found")
 	}
 
-	fmt.Println("Email sent to:", to)
-	return nil
+	return &pb.ChangePasswordResponse{MessagePassword: true}, nil
+}
+
+func newLinkToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func (repo *UserRepository) Logout(ctx context.Context, request *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+// hashLinkToken is what gets stored for tokens sent in emailed links, so a
+// database leak does not expose usable links.
+func hashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (repo *UserRepository) Logout(ctx context.Context, request *pb.LogoutRequest) (_ *pb.LogoutResponse, err error) {
+	ctx, span := startSpan(ctx, "Logout")
+	defer func() { endSpan(span, err) }()
 	query := `UPDATE users
 			SET token = NULL
-			WHERE id = $1 and deleted_at = 0`
+			WHERE id = $1 AND deleted_at IS NULL`
 
 	result, err := repo.Db.ExecContext(ctx, query, request.UserId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update user token: %v", err)
+		return nil, mapError(err, "user not found")
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+		return nil, apperrors.Internal(err)
 	}
 
 	if rowsAffected == 0 {
-		return nil, fmt.Errorf("user not found or token update failed")
+		return nil, apperrors.NotFound("user not found")
 	}
 
 	return &pb.LogoutResponse{
@@ -251,130 +474,279 @@ func (repo *UserRepository) Logout(ctx context.Context, request *pb.LogoutReques
 	}, nil
 }
 
-func (repo *UserRepository) GetFollowersByUserID(ctx context.Context, request *pb.FollowersRequest) (*pb.FollowersResponse, error) {
-	rows, err := repo.Db.QueryContext(ctx,
-		`SELECT id, username, full_name FROM followers WHERE user_id = $1 and deleted_at = 0`,
-		request.UserId,
-	)
+// Follow makes req.FollowerId follow req.FollowingId and notifies the
+// followed user. Following a private account files a follow request for it
+// to approve instead. Following again, or asking again, is a no-op, so the
+// notification only goes out once. Users who blocked each other cannot
+// follow one another.
+func (repo *UserRepository) Follow(ctx context.Context, req *pb.FollowRequest) (_ *pb.FollowResponce, err error) {
+	ctx, span := startSpan(ctx, "Follow")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	blocked, _, err := blockBetween(ctx, tx, req.FollowerId, req.FollowingId)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	if blocked {
+		return nil, apperrors.Forbidden("you cannot follow this user")
+	}
 
-	var followers []*pb.Followers
-	for rows.Next() {
-		var id, username, fullName string
-		if err := rows.Scan(&id, &username, &fullName); err != nil {
+	var followedAt time.Time
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO
+	  followers(
+		follower_id,
+		following_id
+	  )
+	SELECT $1, $2
+	WHERE EXISTS (SELECT 1 FROM users WHERE id = $2 AND deleted_at IS NULL AND NOT is_private)
+	ON CONFLICT (follower_id, following_id) DO NOTHING
+	RETURNING
+	  followed_at
+	`, req.FollowerId, req.FollowingId).Scan(&followedAt)
+	switch {
+	case err == nil:
+		if err = adjustFollowCounts(ctx, tx, req.FollowerId, req.FollowingId, 1); err != nil {
 			return nil, err
 		}
-		follower := &pb.Followers{
-			Id:       id,
-			Username: username,
-			FullName: fullName,
+		if err = repo.notify(ctx, tx, req.FollowingId, notification.NewFollower, req.FollowerId); err != nil {
+			return nil, err
 		}
-		followers = append(followers, follower)
+	case errors.Is(err, sql.ErrNoRows):
+		// Already following, a private account or no such user.
+		if err = repo.requestFollow(ctx, tx, req.FollowerId, req.FollowingId); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, mapError(err, "user not found")
 	}
 
-	if err := rows.Err(); err != nil {
+	res, err := followState(ctx, tx, req.FollowerId, req.FollowingId)
+	if err != nil {
 		return nil, err
 	}
-
-	return &pb.FollowersResponse{Followers: followers}, nil
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+	return res, nil
 }
 
-func (repo *UserRepository) Follow(ctx context.Context, req *pb.FollowRequest) (*pb.FollowResponce, error) {
-	res := pb.FollowResponce{}
-	err := repo.Db.QueryRow(`
-	INSERT INTO
-	  Followers(
-		follower_id,
-		following_id
-	  )
-	  VALUES(
-		$1,
-		$2  
-	  )
-	  Returning
-		follower_id,
-		following_id,
-		followed_at
-	`, req.FollowerId, req.FollowingId).Scan(
-		&res.FollowerId,
-		&res.FollowingId,
-		&res.FollowedAt,
+// UnfollowUser stops req.FollowerId from following req.FollowingId and
+// withdraws a pending follow request. It succeeds when there was nothing to
+// undo.
+func (repo *UserRepository) UnfollowUser(ctx context.Context, req *pb.FollowRequest) (_ *pb.FollowResponce, err error) {
+	ctx, span := startSpan(ctx, "UnfollowUser")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"DELETE FROM followers WHERE follower_id = $1 AND following_id = $2",
+		req.FollowerId, req.FollowingId,
 	)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		if err = adjustFollowCounts(ctx, tx, req.FollowerId, req.FollowingId, -1); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = tx.ExecContext(ctx,
+		"DELETE FROM follow_requests WHERE requester_id = $1 AND target_id = $2",
+		req.FollowerId, req.FollowingId,
+	); err != nil {
+		return nil, mapError(err, "")
+	}
 
+	res, err := followState(ctx, tx, req.FollowerId, req.FollowingId)
 	if err != nil {
 		return nil, err
 	}
-	return &res, nil
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+	return res, nil
 }
 
-func (repo *UserRepository) FollowersUsers(ctx context.Context, req *pb.FollowersRequest) (*pb.FollowersResponce, error) {
-	rows, err := repo.Db.Query(`
+// adjustFollowCounts adds delta to the following count of follower and the
+// followers count of following. Both rows are locked in id order so
+// concurrent follows between the same two users cannot deadlock.
+func adjustFollowCounts(ctx context.Context, tx *sql.Tx, follower, following string, delta int) error {
+	_, err := tx.ExecContext(ctx, `
+	UPDATE users SET
+		following_count = following_count + CASE WHEN id = $1 THEN $3 ELSE 0 END,
+		followers_count = followers_count + CASE WHEN id = $2 THEN $3 ELSE 0 END
+	WHERE id IN (SELECT id FROM users WHERE id IN ($1, $2) ORDER BY id FOR UPDATE)
+	`, follower, following, delta)
+	return mapError(err, "")
+}
+
+// ReconcileFollowCounts recomputes the follow counts from the followers
+// table and fixes the users whose stored counts drifted, for instance
+// because someone they follow deleted their account. It returns how many
+// users were corrected.
+func (repo *UserRepository) ReconcileFollowCounts(ctx context.Context) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "ReconcileFollowCounts")
+	defer func() { endSpan(span, err) }()
+
+	result, err := repo.Db.ExecContext(ctx, `
+	WITH counts AS (
+		SELECT
+			u.id,
+			(SELECT COUNT(*) FROM followers f JOIN users o ON o.id = f.follower_id AND o.deleted_at IS NULL
+			 WHERE f.following_id = u.id) AS followers,
+			(SELECT COUNT(*) FROM followers f JOIN users o ON o.id = f.following_id AND o.deleted_at IS NULL
+			 WHERE f.follower_id = u.id) AS following
+		FROM users u
+		WHERE u.deleted_at IS NULL
+	)
+	UPDATE users u SET followers_count = c.followers, following_count = c.following
+	FROM counts c
+	WHERE u.id = c.id AND (u.followers_count <> c.followers OR u.following_count <> c.following)
+	`)
+	if err != nil {
+		return 0, mapError(err, "")
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, apperrors.Internal(err)
+	}
+	return n, nil
+}
+
+// followState reports how viewer relates to target, or NotFound when target
+// does not exist or was deleted.
+func followState(ctx context.Context, tx *sql.Tx, viewer, target string) (*pb.FollowResponce, error) {
+	var exists bool
+	var followedAt sql.NullTime
+	rel := &pb.Relationship{}
+	err := tx.QueryRowContext(ctx, `
+	SELECT
+	  EXISTS (SELECT 1 FROM users WHERE id = $2 AND deleted_at IS NULL),
+	  (SELECT followed_at FROM followers WHERE follower_id = $1 AND following_id = $2),
+	  EXISTS (SELECT 1 FROM followers WHERE follower_id = $2 AND following_id = $1),
+	  EXISTS (SELECT 1 FROM follow_requests WHERE requester_id = $1 AND target_id = $2),
+	  EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2),
+	  EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = $1 AND muted_id = $2)
+	`, viewer, target).Scan(&exists, &followedAt, &rel.FollowedBy, &rel.Requested, &rel.Blocked, &rel.Muted)
+	if err != nil {
+		return nil, mapError(err, "user not found")
+	}
+	if !exists {
+		return nil, apperrors.NotFound("user not found")
+	}
+
+	res := &pb.FollowResponce{FollowerId: viewer, FollowingId: target, Relationship: rel}
+	if followedAt.Valid {
+		rel.Following = true
+		res.FollowedAt = followedAt.Time.UTC().Format(time.RFC3339)
+	}
+	return res, nil
+}
+
+// followSide selects which end of the followers table a listing is about.
+type followSide struct {
+	match, list string
+}
+
+var (
+	// followersOf lists users following the given one.
+	followersOf = followSide{match: "following_id", list: "follower_id"}
+	// followingOf lists users the given one follows.
+	followingOf = followSide{match: "follower_id", list: "following_id"}
+)
+
+// FollowersUsers lists the users following req.UserId, most recent first.
+func (repo *UserRepository) FollowersUsers(ctx context.Context, req *pb.FollowersRequest) (_ *pb.FollowersResponce, err error) {
+	ctx, span := startSpan(ctx, "FollowersUsers")
+	defer func() { endSpan(span, err) }()
+	return repo.listFollows(ctx, req, followersOf)
+}
+
+// FollowingUsers lists the users req.UserId follows, most recent first.
+func (repo *UserRepository) FollowingUsers(ctx context.Context, req *pb.FollowersRequest) (_ *pb.FollowersResponce, err error) {
+	ctx, span := startSpan(ctx, "FollowingUsers")
+	defer func() { endSpan(span, err) }()
+	return repo.listFollows(ctx, req, followingOf)
+}
+
+// listFollows loads one page together with the total in a single query.
+// Deleted users are left out of both. The lists are hidden like the
+// profile itself: users who blocked the viewer are not found and private
+// accounts answer only themselves and their followers.
+func (repo *UserRepository) listFollows(ctx context.Context, req *pb.FollowersRequest, side followSide) (*pb.FollowersResponce, error) {
+	var isPrivate, following bool
+	err := repo.Db.QueryRowContext(ctx, `
 	SELECT
-	  following_id
+	  u.is_private,
+	  EXISTS (SELECT 1 FROM followers WHERE follower_id = NULLIF($2, '')::uuid AND following_id = u.id)
+	FROM users u
+	WHERE u.id = $1 AND u.deleted_at IS NULL AND `+visibleToViewer+`
+	`, req.UserId, req.ViewerId).Scan(&isPrivate, &following)
+	if err != nil {
+		return nil, mapError(err, "user not found")
+	}
+	if hidesDetails(isPrivate, req.ViewerId == req.UserId, following) {
+		return nil, apperrors.Forbidden("this account is private")
+	}
+
+	page, limit := pageBounds(req.Page, req.Limit)
+	from := fmt.Sprintf(`
 	FROM
-	  Followers
+	  followers f
+	JOIN
+	  users u ON u.id = f.%s
 	WHERE
-	  follower_id = $1
+	  f.%s = $1 AND u.deleted_at IS NULL`, side.list, side.match)
+
+	rows, err := repo.Db.QueryContext(ctx, `
+	SELECT
+	  u.id,
+	  u.username,
+	  u.full_name,
+	  f.followed_at,
+	  COUNT(*) OVER ()`+from+`
+	ORDER BY
+	  f.followed_at DESC, u.id
 	OFFSET $2
 	LIMIT $3
-	and deleted_at is null
-	`, req.UserId, (req.Page-1)*req.Limit, req.Limit)
-
+	`, req.UserId, (page-1)*limit, limit)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err, "")
 	}
+	defer rows.Close()
 
-	var followers []*pb.Follower
+	response := &pb.FollowersResponce{Followers: []*pb.Follower{}, Page: page, Limit: limit}
 	for rows.Next() {
-		var userId string
-		err = rows.Scan(&userId)
-		if err != nil {
-			return nil, err
-		}
 		var follower pb.Follower
-		err = repo.Db.QueryRow(`
-	  SELECT
-		id,
-		username,
-		full_name
-	  FROM
-		Users
-	  WHERE
-		id = $1 and deleted_at is null
-	  `, userId).Scan(
-			&follower.Id,
-			&follower.UserName,
-			&follower.FullName,
-		)
-
-		if err != nil {
-			return nil, err
+		var followedAt time.Time
+		if err = rows.Scan(&follower.Id, &follower.UserName, &follower.FullName, &followedAt, &response.Total); err != nil {
+			return nil, mapError(err, "")
 		}
-
-		followers = append(followers, &follower)
+		follower.FollowedAt = followedAt.UTC().Format(time.RFC3339)
+		response.Followers = append(response.Followers, &follower)
 	}
-	var total int32
-	err = repo.Db.QueryRow(`
-	SELECT
-	  COUNT(*)
-	FROM
-	  Followers
-	WHERE
-	  follower_id = $1 and 
-	  deleted_at is null
-	`, req.UserId).Scan(&total)
-
-	if err != nil {
-		return nil, err
+	if err = rows.Err(); err != nil {
+		return nil, mapError(err, "")
+	}
+	if len(response.Followers) > 0 {
+		return response, nil
 	}
 
-	return &pb.FollowersResponce{
-		Followers: followers,
-		Total:     total,
-		Page:      req.Page,
-		Limit:     req.Limit,
-	}, nil
+	// An empty page carries no total; it may just be past the end.
+	if err = repo.Db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, req.UserId).Scan(&response.Total); err != nil {
+		return nil, mapError(err, "")
+	}
+	return response, nil
 }
@@ -6,9 +6,20 @@ import (
 	"testing"
 	"time"
 
+	"Auth-Service/apperrors"
+	"Auth-Service/config"
 	pb "Auth-Service/genproto/users"
+	"Auth-Service/notification"
+	"Auth-Service/search"
+	"Auth-Service/tracing"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func setupTestDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
@@ -23,7 +34,7 @@ func TestRegister(t *testing.T) {
 	db, mock := setupTestDB(t)
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, &config.Config{})
 
 	ctx := context.Background()
 	req := &pb.RegisterRequest{
@@ -34,7 +45,7 @@ func TestRegister(t *testing.T) {
 	}
 
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs(req.Username, req.Email, req.Password, req.FullName).
+		WithArgs(req.Username, "testuser", req.Email, "test@example.com", req.Password, req.FullName).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("12345", time.Now()))
 
 	resp, err := repo.Register(ctx, req)
@@ -51,7 +62,7 @@ func TestLogin(t *testing.T) {
 	db, mock := setupTestDB(t)
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, &config.Config{})
 
 	ctx := context.Background()
 	req := &pb.LoginRequest{
@@ -59,9 +70,9 @@ func TestLogin(t *testing.T) {
 		Password: "password",
 	}
 
-	mock.ExpectQuery("SELECT id, username, email, full_name, created_at FROM users WHERE username = \\$1 AND password = \\$2").
+	mock.ExpectQuery("SELECT id, username, email, full_name, created_at, deleted_at, banned_at, suspended_until FROM users WHERE username_canonical = \\$1 AND password = \\$2").
 		WithArgs(req.Username, req.Password).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "created_at"}).AddRow("12345", "testuser", "test@example.com", "Test User", time.Now()))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "created_at", "deleted_at", "banned_at", "suspended_until"}).AddRow("12345", "testuser", "test@example.com", "Test User", time.Now(), nil, nil, nil))
 
 	resp, err := repo.Login(ctx, req)
 
@@ -73,19 +84,64 @@ func TestLogin(t *testing.T) {
 	assert.Equal(t, "12345", resp.Id)
 }
 
+func TestLoginByEmailIgnoresCase(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT id, username, email, full_name, created_at, deleted_at, banned_at, suspended_until FROM users WHERE email_canonical = \\$1 AND password = \\$2").
+		WithArgs("test@example.com", "password").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "created_at", "deleted_at", "banned_at", "suspended_until"}).AddRow("12345", "testuser", "Test@Example.com", "Test User", time.Now(), nil, nil, nil))
+
+	resp, err := repo.Login(context.Background(), &pb.LoginRequest{Username: " Test@Example.COM", Password: "password"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", resp.Id)
+}
+
+func TestLoginInvalidCredentials(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT id, username, email, full_name, created_at, deleted_at, banned_at, suspended_until FROM users").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.Login(context.Background(), &pb.LoginRequest{Username: "testuser", Password: "wrong"})
+
+	assert.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+}
+
+func TestRegisterDuplicateUsername(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("INSERT INTO users").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "users_username_canonical_key"})
+
+	_, err := repo.Register(context.Background(), &pb.RegisterRequest{Username: "testuser"})
+
+	assert.ErrorIs(t, err, apperrors.ErrConflict)
+	assert.Equal(t, "username is already taken", apperrors.From(err).Message)
+}
+
 func TestGetUserByID(t *testing.T) {
 	db, mock := setupTestDB(t)
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, &config.Config{})
 
 	ctx := context.Background()
 	userID := "12345"
 
-	mock.ExpectQuery("SELECT username, email, password, full_name FROM users WHERE id = $1 AND deleted_at IS NULL").
+	mock.ExpectQuery("SELECT username, email, password, full_name, bio, countries_visited FROM users WHERE id = \\$1 AND deleted_at IS NULL").
 		WithArgs(userID).
 		WillReturnRows(sqlmock.NewRows([]string{"username", "email", "password", "full_name", "bio", "countries_visited"}).
-			AddRow("vali", "vali12@gmail.com", "vali", "Vali Aliyev"))
+			AddRow("vali", "vali12@gmail.com", "vali", "Vali Aliyev", nil, 0))
 
 	resp, err := repo.GetUserByID(ctx, userID)
 
@@ -101,22 +157,25 @@ func TestProfile(t *testing.T) {
 	db, mock := setupTestDB(t)
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, &config.Config{})
 
 	ctx := context.Background()
 	req := &pb.ProfileRequest{
 		UserId: "12345",
 	}
 
-	mock.ExpectQuery("SELECT id, username, email, full_name, bio, countries_visited, created_at, updated_at FROM users WHERE id=\\$1 AND deleted_at IS NULL").
-		WithArgs(req.UserId).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "bio", "countries_visited", "created_at", "updated_at"}).
-			AddRow("12345", "testuser", "test@example.com", "Test User", "Bio", 5, time.Now(), time.Now()))
+	mock.ExpectQuery("SELECT u.id, u.username, u.email, u.full_name, u.bio, u.countries_visited, u.created_at, u.updated_at, u.followers_count, u.following_count").
+		WithArgs(req.UserId, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "bio", "countries_visited", "created_at", "updated_at", "followers_count", "following_count", "is_private", "following", "followed_by", "requested", "blocked", "muted"}).
+			AddRow("12345", "testuser", "test@example.com", "Test User", "Bio", 5, time.Now(), time.Now(), 7, 3, false, false, false, false, false, false))
 
 	resp, err := repo.Profile(ctx, req)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
+	assert.Equal(t, int32(7), resp.FollowersCount)
+	assert.Equal(t, int32(3), resp.FollowingCount)
+	assert.Nil(t, resp.Relationship)
 	assert.Equal(t, "12345", resp.Id)
 	assert.Equal(t, "testuser", resp.Username)
 	assert.Equal(t, "test@example.com", resp.Email)
@@ -125,24 +184,69 @@ func TestProfile(t *testing.T) {
 	assert.Equal(t, int32(5), resp.CountriesVisited)
 }
 
+func TestProfileRelationship(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("FROM users u WHERE u.id = \\$1 AND u.deleted_at IS NULL").
+		WithArgs("12345", "67890").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "bio", "countries_visited", "created_at", "updated_at", "followers_count", "following_count", "is_private", "following", "followed_by", "requested", "blocked", "muted"}).
+			AddRow("12345", "testuser", "test@example.com", "Test User", nil, 0, time.Now(), time.Now(), 1, 1, false, true, false, false, false, false))
+
+	resp, err := repo.Profile(context.Background(), &pb.ProfileRequest{UserId: "12345", ViewerId: "67890"})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, resp.Relationship) {
+		assert.True(t, resp.Relationship.Following)
+		assert.False(t, resp.Relationship.FollowedBy)
+	}
+}
+
+func TestPrivateProfileHidesDetailsFromNonFollowers(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("FROM users u WHERE u.id = \\$1 AND u.deleted_at IS NULL").
+		WithArgs("12345", "67890").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "bio", "countries_visited", "created_at", "updated_at", "followers_count", "following_count", "is_private", "following", "followed_by", "requested", "blocked", "muted"}).
+			AddRow("12345", "testuser", "test@example.com", "Test User", "Bio", 5, time.Now(), time.Now(), 1, 1, true, false, false, true, false, false))
+
+	resp, err := repo.Profile(context.Background(), &pb.ProfileRequest{UserId: "12345", ViewerId: "67890"})
+
+	assert.NoError(t, err)
+	assert.True(t, resp.IsPrivate)
+	assert.Equal(t, "testuser", resp.Username)
+	assert.Empty(t, resp.Email)
+	assert.Empty(t, resp.Bio)
+	assert.Zero(t, resp.CountriesVisited)
+	assert.True(t, resp.Relationship.Requested)
+}
+
 func TestUpdateProfile(t *testing.T) {
 	db, mock := setupTestDB(t)
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, &config.Config{})
 
 	ctx := context.Background()
+	fullName, bio, countries := "Updated User", "Updated Bio", int32(10)
 	req := &pb.UpdateProfileRequest{
 		Id:               "12345",
-		FullName:         "Updated User",
-		Bio:              "Updated Bio",
-		CountriesVisited: 10,
+		FullName:         &fullName,
+		Bio:              &bio,
+		CountriesVisited: &countries,
 	}
 
-	mock.ExpectQuery("UPDATE users SET full_name = \\$1, bio = \\$2, countries_visited = \\$3, updated_at = \\$4 WHERE id = \\$5 RETURNING id, username, email, full_name, bio, countries_visited, updated_at").
-		WithArgs(req.FullName, req.Bio, req.CountriesVisited, sqlmock.AnyArg(), req.Id).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "bio", "countries_visited", "updated_at"}).
-			AddRow("12345", "testuser", "test@example.com", "Updated User", "Updated Bio", 10, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE users SET full_name = COALESCE\\(\\$1, full_name\\), bio = COALESCE\\(\\$2, bio\\), countries_visited = COALESCE\\(\\$3, countries_visited\\), is_private = COALESCE\\(\\$6, is_private\\), updated_at = \\$4 WHERE id = \\$5 AND deleted_at IS NULL RETURNING id, username, email, full_name, bio, COALESCE\\(countries_visited, 0\\), updated_at, is_private").
+		WithArgs(fullName, bio, countries, sqlmock.AnyArg(), req.Id, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "bio", "countries_visited", "updated_at", "is_private"}).
+			AddRow("12345", "testuser", "test@example.com", "Updated User", "Updated Bio", 10, time.Now(), false))
+	mock.ExpectCommit()
 
 	resp, err := repo.UpdateProfile(ctx, req)
 
@@ -156,20 +260,65 @@ func TestUpdateProfile(t *testing.T) {
 	assert.Equal(t, int32(10), resp.CountriesVisited)
 }
 
+func TestUpdateProfileLeavesUnsetFieldsAlone(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+	private := true
+
+	// A new account has a NULL bio, which the update must not choke on.
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE users SET full_name = COALESCE\\(\\$1, full_name\\)").
+		WithArgs(nil, nil, nil, sqlmock.AnyArg(), "12345", true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "bio", "countries_visited", "updated_at", "is_private"}).
+			AddRow("12345", "testuser", "test@example.com", "Test User", nil, 0, time.Now(), true))
+	mock.ExpectCommit()
+
+	resp, err := repo.UpdateProfile(context.Background(), &pb.UpdateProfileRequest{Id: "12345", IsPrivate: &private})
+
+	require.NoError(t, err)
+	assert.Empty(t, resp.Bio)
+	assert.True(t, resp.IsPrivate)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateProfileClearsBio(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+	empty, zero := "", int32(0)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE users SET full_name").
+		WithArgs(nil, "", int32(0), sqlmock.AnyArg(), "12345", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "bio", "countries_visited", "updated_at", "is_private"}).
+			AddRow("12345", "testuser", "test@example.com", "Test User", "", 0, time.Now(), false))
+	mock.ExpectCommit()
+
+	resp, err := repo.UpdateProfile(context.Background(), &pb.UpdateProfileRequest{Id: "12345", Bio: &empty, CountriesVisited: &zero})
+
+	require.NoError(t, err)
+	assert.Empty(t, resp.Bio)
+	assert.Zero(t, resp.CountriesVisited)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestDeleteUser(t *testing.T) {
 	db, mock := setupTestDB(t)
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, &config.Config{})
 
 	ctx := context.Background()
 	req := &pb.DeleteUserRequest{
 		Id: "12345",
 	}
 
-	mock.ExpectExec("UPDATE users SET deleted_at=CURRENT_TIMESTAMP WHERE id=\\$1 AND deleted_at IS NULL").
+	mock.ExpectQuery("UPDATE users SET deleted_at=CURRENT_TIMESTAMP, tokens_not_before=CURRENT_TIMESTAMP WHERE id=\\$1 AND deleted_at IS NULL").
 		WithArgs(req.Id).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_not_before"}).AddRow(time.Now()))
 
 	resp, err := repo.DeleteUser(ctx, req)
 
@@ -177,3 +326,1004 @@ func TestDeleteUser(t *testing.T) {
 	assert.NotNil(t, resp)
 	assert.True(t, resp.StatusUser)
 }
+
+func TestDeleteUserNotFound(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("UPDATE users SET deleted_at").
+		WithArgs("12345").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: "12345"})
+
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}
+
+func TestRegisterRecordsSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := tracing.NewProvider(exporter, config.TracingConfig{ServiceName: "test", SampleRatio: 1})
+	assert.NoError(t, err)
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("INSERT INTO users").
+		WillReturnError(sql.ErrConnDone)
+
+	_, err = repo.Register(context.Background(), &pb.RegisterRequest{Username: "testuser"})
+	assert.Error(t, err)
+
+	assert.NoError(t, provider.ForceFlush(context.Background()))
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "UserRepository.Register", spans[0].Name)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+	}
+}
+
+func TestCheckUsernameTakenSuggestsAlternatives(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT username_canonical FROM users WHERE username_canonical = ANY").
+		WillReturnRows(sqlmock.NewRows([]string{"username_canonical"}).AddRow("alice"))
+	mock.ExpectQuery("SELECT username_canonical FROM users WHERE username_canonical = ANY").
+		WillReturnRows(sqlmock.NewRows([]string{"username_canonical"}).AddRow("alice_travels"))
+
+	resp, err := repo.CheckUsername(context.Background(), &pb.CheckUsernameRequest{Username: "Alice"})
+
+	assert.NoError(t, err)
+	assert.False(t, resp.Available)
+	assert.Equal(t, UsernameTaken, resp.Reason)
+	assert.Len(t, resp.Suggestions, maxSuggestions)
+	assert.NotContains(t, resp.Suggestions, "Alice_travels")
+}
+
+func TestCheckUsernameReserved(t *testing.T) {
+	db, _ := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	resp, err := repo.CheckUsername(context.Background(), &pb.CheckUsernameRequest{Username: "admin"})
+
+	assert.NoError(t, err)
+	assert.False(t, resp.Available)
+	assert.Equal(t, UsernameReserved, resp.Reason)
+}
+
+func TestChangeUsernameRecordsHistory(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{Username: config.UsernameConfig{
+		ChangeCooldown: 24 * time.Hour,
+		RedirectGrace:  time.Hour,
+		HoldPeriod:     2 * time.Hour,
+	}})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username, username_canonical, username_changed_at FROM users").
+		WithArgs("12345").
+		WillReturnRows(sqlmock.NewRows([]string{"username", "username_canonical", "username_changed_at"}).
+			AddRow("Alice", "alice", time.Now().Add(-48*time.Hour)))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("wanderer", "12345").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("UPDATE users SET username = \\$1, username_canonical = \\$2, username_changed_at = NOW\\(\\)").
+		WithArgs("Wanderer", "wanderer", "12345").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM username_history").
+		WithArgs("12345", "wanderer").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO username_history").
+		WithArgs("12345", "Alice", "alice", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	resp, err := repo.ChangeUsername(context.Background(), &pb.ChangeUsernameRequest{UserId: "12345", Username: "Wanderer"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Wanderer", resp.Username)
+	assert.Equal(t, "Alice", resp.PreviousUsername)
+	assert.NotEmpty(t, resp.NextChangeAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangeUsernameCooldown(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{Username: config.UsernameConfig{ChangeCooldown: 24 * time.Hour}})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username, username_canonical, username_changed_at FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"username", "username_canonical", "username_changed_at"}).
+			AddRow("Alice", "alice", time.Now().Add(-time.Hour)))
+	mock.ExpectRollback()
+
+	_, err := repo.ChangeUsername(context.Background(), &pb.ChangeUsernameRequest{UserId: "12345", Username: "Wanderer"})
+
+	assert.ErrorIs(t, err, apperrors.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, user_id, new_email, new_email_canonical FROM email_change_requests").
+		WithArgs(hashLinkToken("confirm-token")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "new_email", "new_email_canonical"}).
+			AddRow("req-1", "12345", "New@Example.com", "new@example.com"))
+	mock.ExpectExec("UPDATE users SET email = \\$1, email_canonical = \\$2, email_verified_at = NOW\\(\\)").
+		WithArgs("New@Example.com", "new@example.com", "12345").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE email_change_requests SET confirmed_at = NOW\\(\\)").
+		WithArgs("req-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp, err := repo.ConfirmEmailChange(context.Background(), &pb.EmailChangeTokenRequest{Token: "confirm-token"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "New@Example.com", resp.Email)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCancelEmailChangeInvalidToken(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("UPDATE email_change_requests SET cancelled_at = NOW\\(\\)").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.CancelEmailChange(context.Background(), &pb.EmailChangeTokenRequest{Token: "stale"})
+
+	assert.ErrorIs(t, err, apperrors.ErrValidation)
+}
+
+func TestResetPasswordQueuesEmail(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{PublicURL: "https://example.com", Password: config.PasswordConfig{ResetTTL: time.Hour}})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, email, locale FROM users WHERE email_canonical = \\$1").
+		WithArgs("alice@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "locale"}).AddRow("12345", "Alice@Example.com", "uz"))
+	mock.ExpectExec("INSERT INTO password_reset_tokens").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO email_outbox \\(recipient, template, locale, data\\)").
+		WithArgs("Alice@Example.com", "password_reset", "uz", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	_, err := repo.ResetPassword(context.Background(), &pb.ResetPasswordRequest{Email: "ALICE@example.com"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetPasswordUnknownEmailQueuesNothing(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, email, locale FROM users").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	resp, err := repo.ResetPassword(context.Background(), &pb.ResetPasswordRequest{Email: "nobody@example.com"})
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.Message)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimEmails(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("UPDATE email_outbox o SET attempts = o.attempts \\+ 1").
+		WithArgs(10, float64(60)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "recipient", "template", "locale", "data", "attempts"}).
+			AddRow(7, "alice@example.com", "password_reset", "", []byte(`{"Link":"https://example.com/x"}`), 2))
+
+	batch, err := repo.ClaimEmails(context.Background(), 10, time.Minute)
+
+	assert.NoError(t, err)
+	if assert.Len(t, batch, 1) {
+		assert.Equal(t, int64(7), batch[0].ID)
+		assert.Equal(t, "https://example.com/x", batch[0].Data["Link"])
+		assert.Equal(t, 2, batch[0].Attempts)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowNotifiesByPreference(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{PublicURL: "https://example.com"})
+
+	mock.ExpectBegin()
+	expectBlockBetween(mock, false, false)
+	mock.ExpectQuery("INSERT INTO followers").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"followed_at"}).AddRow(time.Now()))
+	mock.ExpectExec("UPDATE users SET following_count = following_count \\+ CASE WHEN id = \\$1 THEN \\$3 ELSE 0 END").
+		WithArgs("follower", "followed", 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	// In-app notifications are switched off, email stays on.
+	mock.ExpectQuery("LEFT JOIN notification_preferences p").
+		WithArgs("followed", "new_follower", true, true, "follower").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "locale", "in_app", "email", "username"}).
+			AddRow("bob@example.com", "", false, true, "alice"))
+	mock.ExpectExec("INSERT INTO email_outbox").
+		WithArgs("bob@example.com", "new_follower", "", []byte(`{"ActorUsername":"alice","Link":"https://example.com/users/by-username/alice"}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectFollowState(mock, true, time.Now(), &pb.Relationship{})
+	mock.ExpectCommit()
+
+	_, err := repo.Follow(context.Background(), &pb.FollowRequest{FollowerId: "follower", FollowingId: "followed"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectFollowState(mock sqlmock.Sqlmock, exists bool, followedAt any, rel *pb.Relationship) {
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE id = \\$2 AND deleted_at IS NULL\\)").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"exists", "followed_at", "followed_by", "requested", "blocked", "muted"}).
+			AddRow(exists, followedAt, rel.FollowedBy, rel.Requested, rel.Blocked, rel.Muted))
+}
+
+func expectBlockBetween(mock sqlmock.Sqlmock, byFollower, byFollowed bool) {
+	mock.ExpectQuery("FROM user_blocks WHERE blocker_id = \\$1 AND blocked_id = \\$2").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"by_a", "by_b"}).AddRow(byFollower, byFollowed))
+}
+
+func TestFollowTwiceIsIdempotent(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+	followedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectBlockBetween(mock, false, false)
+	mock.ExpectQuery("INSERT INTO followers").
+		WithArgs("follower", "followed").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO follow_requests").
+		WithArgs("follower", "followed").
+		WillReturnError(sql.ErrNoRows)
+	expectFollowState(mock, true, followedAt, &pb.Relationship{FollowedBy: true})
+	mock.ExpectCommit()
+
+	resp, err := repo.Follow(context.Background(), &pb.FollowRequest{FollowerId: "follower", FollowingId: "followed"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-01-02T03:04:05Z", resp.FollowedAt)
+	assert.True(t, resp.Relationship.Following)
+	assert.True(t, resp.Relationship.FollowedBy)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowDeletedUser(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectBegin()
+	expectBlockBetween(mock, false, false)
+	mock.ExpectQuery("INSERT INTO followers").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO follow_requests").
+		WillReturnError(sql.ErrNoRows)
+	expectFollowState(mock, false, nil, &pb.Relationship{})
+	mock.ExpectRollback()
+
+	_, err := repo.Follow(context.Background(), &pb.FollowRequest{FollowerId: "follower", FollowingId: "followed"})
+
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowPrivateUserFilesRequest(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectBegin()
+	expectBlockBetween(mock, false, false)
+	mock.ExpectQuery("INSERT INTO followers").
+		WithArgs("follower", "followed").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO follow_requests").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	mock.ExpectQuery("LEFT JOIN notification_preferences p").
+		WithArgs("followed", "follow_request", true, true, "follower").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "locale", "in_app", "email", "username"}).
+			AddRow("bob@example.com", "", true, false, "alice"))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("followed", "follow_request", "follower").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectFollowState(mock, true, nil, &pb.Relationship{Requested: true})
+	mock.ExpectCommit()
+
+	resp, err := repo.Follow(context.Background(), &pb.FollowRequest{FollowerId: "follower", FollowingId: "followed"})
+
+	assert.NoError(t, err)
+	assert.Empty(t, resp.FollowedAt)
+	assert.False(t, resp.Relationship.Following)
+	assert.True(t, resp.Relationship.Requested)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApproveFollowRequest(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+	followedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM follow_requests WHERE requester_id = \\$1 AND target_id = \\$2 RETURNING requester_id").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"requester_id"}).AddRow("follower"))
+	mock.ExpectQuery("INSERT INTO followers").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"followed_at"}).AddRow(followedAt))
+	mock.ExpectExec("UPDATE users SET following_count").
+		WithArgs("follower", "followed", 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("LEFT JOIN notification_preferences p").
+		WithArgs("follower", "follow_accepted", true, false, "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "locale", "in_app", "email", "username"}).
+			AddRow("alice@example.com", "", true, false, "bob"))
+	mock.ExpectExec("INSERT INTO notifications").
+		WithArgs("follower", "follow_accepted", "followed").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	resp, err := repo.ApproveFollowRequest(context.Background(), &pb.FollowRequestAction{UserId: "followed", RequesterId: "follower"})
+
+	assert.NoError(t, err)
+	assert.True(t, resp.Approved)
+	assert.Equal(t, "2026-01-02T03:04:05Z", resp.FollowedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApproveFollowRequestAlreadyFollowing(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+	followedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM follow_requests").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"requester_id"}).AddRow("follower"))
+	mock.ExpectQuery("INSERT INTO followers").
+		WithArgs("follower", "followed").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT followed_at FROM followers WHERE follower_id = \\$1 AND following_id = \\$2").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"followed_at"}).AddRow(followedAt))
+	mock.ExpectCommit()
+
+	resp, err := repo.ApproveFollowRequest(context.Background(), &pb.FollowRequestAction{UserId: "followed", RequesterId: "follower"})
+
+	require.NoError(t, err)
+	assert.True(t, resp.Approved)
+	assert.Equal(t, "2026-01-02T03:04:05Z", resp.FollowedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApproveFollowRequestFromDeletedUser(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM follow_requests").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"requester_id"}).AddRow("follower"))
+	mock.ExpectQuery("INSERT INTO followers").
+		WithArgs("follower", "followed").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT followed_at FROM followers").
+		WithArgs("follower", "followed").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectCommit()
+
+	_, err := repo.ApproveFollowRequest(context.Background(), &pb.FollowRequestAction{UserId: "followed", RequesterId: "follower"})
+
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	assert.EqualError(t, err, "user not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRejectMissingFollowRequest(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM follow_requests").
+		WithArgs("follower", "followed").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err := repo.RejectFollowRequest(context.Background(), &pb.FollowRequestAction{UserId: "followed", RequesterId: "follower"})
+
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowBlockedUser(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectBegin()
+	expectBlockBetween(mock, false, true)
+	mock.ExpectRollback()
+
+	_, err := repo.Follow(context.Background(), &pb.FollowRequest{FollowerId: "follower", FollowingId: "followed"})
+
+	assert.ErrorIs(t, err, apperrors.ErrForbidden)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBlockUserRemovesFollowsBothWays(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO user_blocks").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	mock.ExpectQuery("DELETE FROM followers").
+		WithArgs("follower", "followed").
+		WillReturnRows(sqlmock.NewRows([]string{"follower_id", "following_id"}).
+			AddRow("follower", "followed").
+			AddRow("followed", "follower"))
+	mock.ExpectExec("UPDATE users SET following_count").
+		WithArgs("follower", "followed", -1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE users SET following_count").
+		WithArgs("followed", "follower", -1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM follow_requests").
+		WithArgs("follower", "followed").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectFollowState(mock, true, nil, &pb.Relationship{Blocked: true})
+	mock.ExpectCommit()
+
+	resp, err := repo.BlockUser(context.Background(), &pb.RelationshipRequest{UserId: "follower", TargetId: "followed"})
+
+	assert.NoError(t, err)
+	assert.True(t, resp.Relationship.Blocked)
+	assert.False(t, resp.Relationship.Following)
+	assert.False(t, resp.Relationship.FollowedBy)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsBlocked(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	expectBlockBetween(mock, false, true)
+
+	resp, err := repo.IsBlocked(context.Background(), &pb.IsBlockedRequest{UserId: "follower", OtherId: "followed"})
+
+	assert.NoError(t, err)
+	assert.True(t, resp.Blocked)
+	assert.False(t, resp.ByUser)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnfollowUser(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM followers WHERE follower_id = \\$1 AND following_id = \\$2").
+		WithArgs("follower", "followed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET following_count").
+		WithArgs("follower", "followed", -1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM follow_requests WHERE requester_id = \\$1 AND target_id = \\$2").
+		WithArgs("follower", "followed").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectFollowState(mock, true, nil, &pb.Relationship{})
+	mock.ExpectCommit()
+
+	resp, err := repo.UnfollowUser(context.Background(), &pb.FollowRequest{FollowerId: "follower", FollowingId: "followed"})
+
+	assert.NoError(t, err)
+	assert.False(t, resp.Relationship.Following)
+	assert.Empty(t, resp.FollowedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectExec("UPDATE notifications SET read_at = NOW\\(\\)").
+		WithArgs("12345", pq.Array([]string{"n-1"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM notifications").
+		WithArgs("12345").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	resp, err := repo.MarkNotificationsRead(context.Background(), &pb.MarkNotificationsReadRequest{UserId: "12345", Ids: []string{"n-1"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Marked)
+	assert.Equal(t, int32(2), resp.UnreadCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationPreferencesDefaults(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT type, in_app, email FROM notification_preferences").
+		WithArgs("12345").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "in_app", "email"}))
+
+	resp, err := repo.GetNotificationPreferences(context.Background(), &pb.NotificationPreferencesRequest{UserId: "12345"})
+
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Preferences, len(notification.Types())) {
+		for _, p := range resp.Preferences {
+			def := notification.Default(p.Type)
+			assert.Equal(t, def.InApp, p.InApp, p.Type)
+			assert.Equal(t, def.Email, p.Email, p.Type)
+		}
+	}
+}
+
+func TestFollowersUsersListsFollowers(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+	followedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	expectFollowsVisible(mock, "12345", "", false, false)
+	mock.ExpectQuery("JOIN users u ON u.id = f.follower_id WHERE f.following_id = \\$1 AND u.deleted_at IS NULL ORDER BY f.followed_at DESC, u.id").
+		WithArgs("12345", int32(10), int32(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "full_name", "followed_at", "count"}).
+			AddRow("67890", "alice", "Alice", followedAt, 11))
+
+	resp, err := repo.FollowersUsers(context.Background(), &pb.FollowersRequest{UserId: "12345", Page: 2, Limit: 10})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(11), resp.Total)
+	if assert.Len(t, resp.Followers, 1) {
+		assert.Equal(t, "alice", resp.Followers[0].UserName)
+		assert.Equal(t, "2026-01-02T03:04:05Z", resp.Followers[0].FollowedAt)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowingUsersUnknownUser(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT u.is_private").
+		WithArgs("12345", "").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.FollowingUsers(context.Background(), &pb.FollowersRequest{UserId: "12345"})
+
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowersUsersPastLastPage(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	expectFollowsVisible(mock, "12345", "viewer", false, false)
+	mock.ExpectQuery("JOIN users u ON u.id = f.follower_id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "full_name", "followed_at", "count"}))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM followers f").
+		WithArgs("12345").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+	resp, err := repo.FollowersUsers(context.Background(), &pb.FollowersRequest{UserId: "12345", ViewerId: "viewer", Page: 9})
+
+	require.NoError(t, err)
+	assert.Empty(t, resp.Followers)
+	assert.Equal(t, int32(4), resp.Total)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowersUsersHiddenFromBlockedViewer(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	// The owner blocked the viewer, so the visibility query finds no one.
+	mock.ExpectQuery("SELECT u.is_private, EXISTS \\(SELECT 1 FROM followers WHERE follower_id = NULLIF\\(\\$2, ''\\)::uuid AND following_id = u.id\\) FROM users u WHERE u.id = \\$1 AND u.deleted_at IS NULL AND NOT EXISTS \\(SELECT 1 FROM user_blocks WHERE blocker_id = u.id AND blocked_id = NULLIF\\(\\$2, ''\\)::uuid\\)").
+		WithArgs("owner", "viewer").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.FollowersUsers(context.Background(), &pb.FollowersRequest{UserId: "owner", ViewerId: "viewer"})
+
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFollowingUsersOfPrivateAccount(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	// A non-follower is refused before any follow is read.
+	expectFollowsVisible(mock, "owner", "viewer", true, false)
+	_, err := repo.FollowingUsers(context.Background(), &pb.FollowersRequest{UserId: "owner", ViewerId: "viewer"})
+	assert.ErrorIs(t, err, apperrors.ErrForbidden)
+
+	// A follower, and the owner, see the list.
+	for _, viewer := range []string{"follower", "owner"} {
+		expectFollowsVisible(mock, "owner", viewer, true, viewer == "follower")
+		mock.ExpectQuery("JOIN users u ON u.id = f.following_id WHERE f.follower_id = \\$1").
+			WithArgs("owner", int32(0), int32(10)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "full_name", "followed_at", "count"}).
+				AddRow("67890", "alice", "Alice", time.Now(), 1))
+		resp, err := repo.FollowingUsers(context.Background(), &pb.FollowersRequest{UserId: "owner", ViewerId: viewer})
+		require.NoError(t, err)
+		assert.Len(t, resp.Followers, 1)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectFollowsVisible(mock sqlmock.Sqlmock, owner, viewer string, private, following bool) {
+	mock.ExpectQuery("SELECT u.is_private").
+		WithArgs(owner, viewer).
+		WillReturnRows(sqlmock.NewRows([]string{"is_private", "following"}).AddRow(private, following))
+}
+
+func TestReconcileFollowCounts(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectExec("UPDATE users u SET followers_count = c.followers, following_count = c.following").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	fixed, err := repo.ReconcileFollowCounts(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), fixed)
+}
+
+func TestLoginSuspended(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT id, username, email, full_name, created_at, deleted_at, banned_at, suspended_until FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "created_at", "deleted_at", "banned_at", "suspended_until"}).
+			AddRow("12345", "testuser", "test@example.com", "Test User", time.Now(), nil, nil, time.Now().Add(time.Hour)))
+
+	_, err := repo.Login(context.Background(), &pb.LoginRequest{Username: "testuser", Password: "password"})
+
+	assert.ErrorIs(t, err, apperrors.ErrForbidden)
+}
+
+func TestLoginDeactivated(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT id, username, email, full_name, created_at, deleted_at, banned_at, suspended_until FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "created_at", "deleted_at", "banned_at", "suspended_until"}).
+			AddRow("12345", "testuser", "test@example.com", "Test User", time.Now(), time.Now(), nil, nil))
+
+	_, err := repo.Login(context.Background(), &pb.LoginRequest{Username: "testuser", Password: "password"})
+
+	assert.ErrorIs(t, err, apperrors.ErrForbidden)
+	assert.Contains(t, err.Error(), "deactivated")
+}
+
+type cutoffCache map[string]time.Time
+
+func (c cutoffCache) Remember(_ context.Context, userID string, notBefore time.Time) error {
+	c[userID] = notBefore
+	return nil
+}
+
+var reportRowColumns = []string{"id", "reporter_id", "reported_id", "reason", "details", "status", "moderator_id", "action", "note", "created_at", "claimed_at", "resolved_at"}
+
+func TestListReportsRequiresModerator(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT role = 'moderator' FROM users WHERE id = \\$1").
+		WithArgs("user").
+		WillReturnRows(sqlmock.NewRows([]string{"moderator"}).AddRow(false))
+
+	_, err := repo.ListReports(context.Background(), &pb.ListReportsRequest{ModeratorId: "user"})
+
+	assert.ErrorIs(t, err, apperrors.ErrForbidden)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimReportClaimedByOther(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT role = 'moderator'").
+		WithArgs("mod").
+		WillReturnRows(sqlmock.NewRows([]string{"moderator"}).AddRow(true))
+	mock.ExpectQuery("UPDATE reports SET status = 'claimed'").
+		WithArgs("mod", "report").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT status FROM reports WHERE id = \\$1").
+		WithArgs("report").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("claimed"))
+
+	_, err := repo.ClaimReport(context.Background(), &pb.ClaimReportRequest{ModeratorId: "mod", ReportId: "report"})
+
+	assert.ErrorIs(t, err, apperrors.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveReportSuspends(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+	cutoffs := cutoffCache{}
+	repo.TokenCutoffs = cutoffs
+	until := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	revokedAt := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT role = 'moderator'").
+		WithArgs("mod").
+		WillReturnRows(sqlmock.NewRows([]string{"moderator"}).AddRow(true))
+	mock.ExpectQuery("UPDATE reports SET status = 'resolved'").
+		WithArgs("mod", "report", "suspend", "spamming").
+		WillReturnRows(sqlmock.NewRows(reportRowColumns).
+			AddRow("report", "reporter", "reported", "spam", "", "resolved", "mod", "suspend", "spamming", time.Now(), time.Now(), time.Now()))
+	mock.ExpectQuery("UPDATE users SET suspended_until").
+		WithArgs("reported", "suspend", int64(259200)).
+		WillReturnRows(sqlmock.NewRows([]string{"email", "locale", "suspended_until", "tokens_not_before"}).AddRow("bob@example.com", "uz", until, revokedAt))
+	mock.ExpectExec("INSERT INTO email_outbox").
+		WithArgs("bob@example.com", "account_moderated", "uz", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("mod", "moderation.suspend", "reported", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	resp, err := repo.ResolveReport(context.Background(), &pb.ResolveReportRequest{
+		ModeratorId: "mod", ReportId: "report", Action: "suspend", SuspendSeconds: 259200, Note: "spamming",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "resolved", resp.Status)
+	assert.Equal(t, "suspend", resp.Action)
+	assert.Equal(t, revokedAt, cutoffs["reported"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUsersPagesWithCursor(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+	after := search.Cursor{Sort: search.SortRelevance, Key: "1.4", ID: "a"}
+
+	mock.ExpectQuery("WITH matches AS .* SELECT COUNT\\(\\*\\) FROM matches WHERE countries_visited >= \\$4").
+		WithArgs("ali_", "ali\\_%", "viewer", int32(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery("WITH matches AS .* SELECT id, username, full_name, countries_visited, score::text FROM matches .* \\(score, id\\) < \\(NULLIF\\(\\$6, ''\\)::float8, .* ORDER BY score DESC, id DESC").
+		WithArgs("ali_", "ali\\_%", "viewer", int32(3), "a", "1.4", int32(0), int32(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "full_name", "countries_visited", "score"}).
+			AddRow("b", "ali_b", "Ali B", 5, "1.3").
+			AddRow("c", "Ali_C", "Ali C", 4, "1.2").
+			AddRow("d", "ali_d", "Ali D", 3, "1.1"))
+
+	resp, err := repo.GetUsers(context.Background(), &pb.GetUsersRequest{
+		Query: "Ali_", MinCountriesVisited: 3, Cursor: after.Encode(), Limit: 2, ViewerId: "viewer",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, int32(12), resp.Total)
+	require.Len(t, resp.Users, 2)
+	assert.Equal(t, "c", resp.Users[1].Id)
+	next, err := search.DecodeCursor(resp.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, search.Cursor{Sort: search.SortRelevance, Key: "1.2", ID: "c"}, next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchGetUsersReportsMissing(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT id, username, username_canonical, full_name, is_private FROM users WHERE deleted_at IS NULL AND \\(id = ANY\\(\\$1::uuid\\[\\]\\) OR username_canonical = ANY\\(\\$2::text\\[\\]\\)\\)").
+		WithArgs(pq.Array([]string{"a", "b"}), pq.Array([]string{"alice", "ghost"})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "username_canonical", "full_name", "is_private"}).
+			AddRow("a", "Bob", "bob", "Bob B", false).
+			AddRow("c", "Alice", "alice", "Alice A", true))
+
+	resp, err := repo.BatchGetUsers(context.Background(), &pb.BatchGetUsersRequest{
+		Ids: []string{"a", "b"}, Usernames: []string{"Alice", "Ghost"},
+	})
+
+	require.NoError(t, err)
+	assert.Len(t, resp.Users, 2)
+	assert.True(t, resp.Users[1].IsPrivate)
+	assert.Equal(t, []string{"b"}, resp.MissingIds)
+	assert.Equal(t, []string{"Ghost"}, resp.MissingUsernames)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchGetUsersUpperCaseID(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+	const id = "0b6e5ab4-3f3c-4c1e-9c47-2f8f0c7d9a10"
+
+	mock.ExpectQuery("SELECT id, username, username_canonical, full_name, is_private FROM users").
+		WithArgs(pq.Array([]string{id}), pq.Array([]string{})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "username_canonical", "full_name", "is_private"}).
+			AddRow(id, "Bob", "bob", "Bob B", false))
+
+	resp, err := repo.BatchGetUsers(context.Background(), &pb.BatchGetUsersRequest{
+		Ids: []string{"0B6E5AB4-3F3C-4C1E-9C47-2F8F0C7D9A10", id},
+	})
+
+	require.NoError(t, err)
+	assert.Len(t, resp.Users, 1)
+	assert.Empty(t, resp.MissingIds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserByUsernameHidesPrivateDetails(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectQuery("SELECT id, username, redirected FROM").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "redirected"}).AddRow("12345", "alice_new", true))
+	mock.ExpectQuery("SELECT u.id, u.username, u.email").
+		WithArgs("12345", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "full_name", "bio", "countries_visited", "created_at", "updated_at", "followers_count", "following_count", "is_private", "following", "followed_by", "requested", "blocked", "muted"}).
+			AddRow("12345", "alice_new", "alice@example.com", "Alice", "Bio", 5, time.Now(), time.Now(), 7, 3, true, false, false, false, false, false))
+
+	resp, err := repo.GetUserByUsername(context.Background(), &pb.GetUserByUsernameRequest{Username: "Alice"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "alice_new", resp.Username)
+	assert.True(t, resp.Redirected)
+	assert.True(t, resp.IsPrivate)
+	assert.Empty(t, resp.Bio)
+	assert.Equal(t, int32(7), resp.FollowersCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecanonicalizeIdentitiesFillsUsersCreatedBeforeMigration(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	// "Straße" registered before migration 000003 and has no canonical forms
+	// yet; "alice" registered after it.
+	mock.ExpectBegin()
+	mock.ExpectExec("LOCK TABLE users").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, username, email, username_canonical, email_canonical FROM users ORDER BY created_at, id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "username_canonical", "email_canonical"}).
+			AddRow("1", "Straße", "Straße@Mail.com", nil, nil).
+			AddRow("2", "alice", "alice@mail.com", "alice", "alice@mail.com"))
+	mock.ExpectExec("UPDATE users SET username_canonical = NULL, email_canonical = NULL WHERE id = ANY\\(\\$1::uuid\\[\\]\\)").
+		WithArgs(pq.Array([]string{"1"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET username_canonical = \\$2, email_canonical = \\$3 WHERE id = \\$1").
+		WithArgs("1", "strasse", "strasse@mail.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("ALTER TABLE users ALTER COLUMN username_canonical SET NOT NULL").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	fixed, err := repo.RecanonicalizeIdentities(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), fixed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecanonicalizeIdentitiesReportsEveryCollision(t *testing.T) {
+	db, mock := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewUserRepository(db, &config.Config{})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("LOCK TABLE users").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, username, email, username_canonical, email_canonical FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "username_canonical", "email_canonical"}).
+			AddRow("1", "Straße", "a@mail.com", nil, nil).
+			AddRow("2", "STRASSE", "b@mail.com", nil, nil).
+			AddRow("3", "carol", "A@Mail.com", nil, nil))
+	mock.ExpectRollback()
+
+	_, err := repo.RecanonicalizeIdentities(context.Background())
+
+	var collisions IdentityCollisions
+	require.ErrorAs(t, err, &collisions)
+	assert.Equal(t, IdentityCollisions{
+		{Kind: "username", Canonical: "strasse", UserIDs: []string{"1", "2"}},
+		{Kind: "email", Canonical: "a@mail.com", UserIDs: []string{"1", "3"}},
+	}, collisions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
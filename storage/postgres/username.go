@@ -0,0 +1,265 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"Auth-Service/apperrors"
+	"Auth-Service/canonical"
+	pb "Auth-Service/genproto/users"
+	"Auth-Service/reserved"
+	"Auth-Service/validation"
+
+	"github.com/lib/pq"
+)
+
+const (
+	UsernameTaken    = "taken"
+	UsernameReserved = "reserved"
+
+	maxSuggestions = 3
+)
+
+// CheckUsername reports whether request.Username can be registered and, if
+// not, suggests up to three similar names that can.
+func (repo *UserRepository) CheckUsername(ctx context.Context, request *pb.CheckUsernameRequest) (_ *pb.CheckUsernameResponse, err error) {
+	ctx, span := startSpan(ctx, "CheckUsername")
+	defer func() { endSpan(span, err) }()
+	username := canonical.Display(request.Username)
+	response := &pb.CheckUsernameResponse{Username: username, Available: true}
+
+	if reserved.IsReserved(username) {
+		response.Available, response.Reason = false, UsernameReserved
+	} else {
+		taken, err := repo.takenUsernames(ctx, []string{username})
+		if err != nil {
+			return nil, err
+		}
+		if len(taken) > 0 {
+			response.Available, response.Reason = false, UsernameTaken
+		}
+	}
+	if response.Available {
+		return response, nil
+	}
+
+	candidates := suggestUsernames(username)
+	taken, err := repo.takenUsernames(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range candidates {
+		if len(response.Suggestions) == maxSuggestions {
+			break
+		}
+		if !taken[canonical.Username(c)] {
+			response.Suggestions = append(response.Suggestions, c)
+		}
+	}
+	return response, nil
+}
+
+// takenUsernames returns the canonical forms of names that are in use or on
+// hold after a username change.
+func (repo *UserRepository) takenUsernames(ctx context.Context, names []string) (map[string]bool, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(names))
+	for i, n := range names {
+		keys[i] = canonical.Username(n)
+	}
+
+	rows, err := repo.Db.QueryContext(ctx, `
+	SELECT username_canonical FROM users WHERE username_canonical = ANY($1)
+	UNION
+	SELECT username_canonical FROM username_history WHERE username_canonical = ANY($1) AND hold_until > NOW()
+	`, pq.Array(keys))
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, mapError(err, "")
+		}
+		taken[key] = true
+	}
+	return taken, mapError(rows.Err(), "")
+}
+
+// suggestUsernames derives candidate names from base that pass the username
+// rules and the reserved list. Availability is checked by the caller.
+func suggestUsernames(base string) []string {
+	if len(base) > validation.UsernameMaxLen-5 {
+		base = base[:validation.UsernameMaxLen-5]
+	}
+	year := time.Now().Year()
+	raw := []string{
+		fmt.Sprintf("%s%d", base, rand.IntN(90)+10),
+		fmt.Sprintf("%s_%d", base, rand.IntN(900)+100),
+		fmt.Sprintf("%s%d", base, year),
+		base + "_travels",
+		base + ".trips",
+		fmt.Sprintf("%s.%d", base, rand.IntN(9000)+1000),
+	}
+
+	var out []string
+	for _, c := range raw {
+		if len(c) <= validation.UsernameMaxLen && validation.Username(c) == "" && !reserved.IsReserved(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ChangeUsername renames a user, keeping the old name in username_history so
+// it redirects to the user for Username.RedirectGrace and cannot be claimed
+// by others for Username.HoldPeriod. Changes that only alter letter case are
+// not subject to the cooldown.
+func (repo *UserRepository) ChangeUsername(ctx context.Context, request *pb.ChangeUsernameRequest) (_ *pb.ChangeUsernameResponse, err error) {
+	ctx, span := startSpan(ctx, "ChangeUsername")
+	defer func() { endSpan(span, err) }()
+	username := canonical.Display(request.Username)
+	key := canonical.Username(username)
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, mapError(err, "")
+	}
+	defer tx.Rollback()
+
+	var previous, previousKey string
+	var changedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT username, username_canonical, username_changed_at FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE",
+		request.UserId,
+	).Scan(&previous, &previousKey, &changedAt)
+	if err != nil {
+		return nil, mapError(err, "user not found")
+	}
+
+	now := time.Now()
+	caseOnly := key == previousKey
+	if !caseOnly && changedAt.Valid {
+		if next := changedAt.Time.Add(repo.Username.ChangeCooldown); now.Before(next) {
+			return nil, apperrors.Conflict(fmt.Sprintf("username can be changed again after %s", next.UTC().Format(time.RFC3339)))
+		}
+	}
+
+	if !caseOnly {
+		var held bool
+		err = tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM username_history WHERE username_canonical = $1 AND user_id <> $2 AND hold_until > NOW())",
+			key, request.UserId,
+		).Scan(&held)
+		if err != nil {
+			return nil, mapError(err, "")
+		}
+		if held {
+			return nil, apperrors.Conflict(conflictMessages["users_username_canonical_key"])
+		}
+	}
+
+	query := "UPDATE users SET username = $1, username_canonical = $2, updated_at = NOW() WHERE id = $3"
+	if !caseOnly {
+		query = "UPDATE users SET username = $1, username_canonical = $2, username_changed_at = NOW(), updated_at = NOW() WHERE id = $3"
+	}
+	if _, err = tx.ExecContext(ctx, query, username, key, request.UserId); err != nil {
+		return nil, mapError(err, "user not found")
+	}
+
+	if !caseOnly {
+		// Taking back one of your own old names ends its redirect.
+		if _, err = tx.ExecContext(ctx,
+			"DELETE FROM username_history WHERE user_id = $1 AND username_canonical = $2",
+			request.UserId, key,
+		); err != nil {
+			return nil, mapError(err, "")
+		}
+		if _, err = tx.ExecContext(ctx,
+			`INSERT INTO username_history (user_id, username, username_canonical, changed_at, redirect_until, hold_until)
+			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			request.UserId, previous, previousKey, now, now.Add(repo.Username.RedirectGrace), now.Add(repo.Username.HoldPeriod),
+		); err != nil {
+			return nil, mapError(err, "")
+		}
+		changedAt = sql.NullTime{Time: now, Valid: true}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, mapError(err, "")
+	}
+	repo.forgetUsers(ctx, request.UserId)
+
+	response := &pb.ChangeUsernameResponse{
+		UserId:           request.UserId,
+		Username:         username,
+		PreviousUsername: previous,
+	}
+	if changedAt.Valid {
+		response.ChangedAt = changedAt.Time.UTC().Format(time.RFC3339)
+		response.NextChangeAt = changedAt.Time.Add(repo.Username.ChangeCooldown).UTC().Format(time.RFC3339)
+	}
+	return response, nil
+}
+
+// ResolveUsername finds the user currently holding username or, failing
+// that, the user who gave it up within the redirect grace period.
+func (repo *UserRepository) ResolveUsername(ctx context.Context, request *pb.ResolveUsernameRequest) (_ *pb.ResolveUsernameResponse, err error) {
+	ctx, span := startSpan(ctx, "ResolveUsername")
+	defer func() { endSpan(span, err) }()
+	response := &pb.ResolveUsernameResponse{}
+	err = repo.Db.QueryRowContext(ctx, `
+	SELECT id, username, redirected FROM (
+		SELECT id, username, false AS redirected, NOW() AS changed_at
+		FROM users
+		WHERE username_canonical = $1 AND deleted_at IS NULL
+		UNION ALL
+		SELECT u.id, u.username, true, h.changed_at
+		FROM username_history h
+		JOIN users u ON u.id = h.user_id
+		WHERE h.username_canonical = $1 AND h.redirect_until > NOW() AND u.deleted_at IS NULL
+	) candidates
+	ORDER BY redirected, changed_at DESC
+	LIMIT 1
+	`, canonical.Username(request.Username)).Scan(&response.UserId, &response.Username, &response.Redirected)
+	if err != nil {
+		return nil, mapError(err, "user not found")
+	}
+	return response, nil
+}
+
+// GetUserByUsername returns the public profile of the user holding username,
+// or recently holding it, as request.ViewerId sees it.
+func (repo *UserRepository) GetUserByUsername(ctx context.Context, request *pb.GetUserByUsernameRequest) (_ *pb.PublicProfile, err error) {
+	ctx, span := startSpan(ctx, "GetUserByUsername")
+	defer func() { endSpan(span, err) }()
+
+	resolved, err := repo.ResolveUsername(ctx, &pb.ResolveUsernameRequest{Username: request.Username})
+	if err != nil {
+		return nil, err
+	}
+	profile, err := repo.Profile(ctx, &pb.ProfileRequest{UserId: resolved.UserId, ViewerId: request.ViewerId})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PublicProfile{
+		Id:               profile.Id,
+		Username:         profile.Username,
+		FullName:         profile.FullName,
+		Bio:              profile.Bio,
+		CountriesVisited: profile.CountriesVisited,
+		CreatedAt:        profile.CreatedAt,
+		FollowersCount:   profile.FollowersCount,
+		FollowingCount:   profile.FollowingCount,
+		IsPrivate:        profile.IsPrivate,
+		Redirected:       resolved.Redirected,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CutoffSource is where token cut-offs are stored for good.
+type CutoffSource interface {
+	TokensNotBefore(ctx context.Context, userID string) (time.Time, error)
+}
+
+// TokenCutoffs caches the time before which a user's tokens are rejected, so
+// authenticating a request does not cost a database query. Values are unix
+// seconds, 0 meaning no cut-off.
+type TokenCutoffs struct {
+	rdb    *redis.Client
+	source CutoffSource
+	ttl    time.Duration
+}
+
+func NewTokenCutoffs(rdb *redis.Client, source CutoffSource, ttl time.Duration) *TokenCutoffs {
+	return &TokenCutoffs{rdb: rdb, source: source, ttl: ttl}
+}
+
+func cutoffKey(userID string) string {
+	return "tokens_not_before:" + userID
+}
+
+// NotBefore returns the user's cut-off, reading it from the source when it is
+// not cached. An unreachable Redis falls back to the source as well.
+func (c *TokenCutoffs) NotBefore(ctx context.Context, userID string) (time.Time, error) {
+	cached, err := c.rdb.Get(ctx, cutoffKey(userID)).Int64()
+	if err == nil {
+		return unixOrZero(cached), nil
+	}
+
+	notBefore, err := c.source.TokensNotBefore(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// SetNX keeps a value stored by Remember in the meantime from being
+	// replaced with the older one read above.
+	c.rdb.SetNX(ctx, cutoffKey(userID), unixOrNone(notBefore), c.ttl)
+	return notBefore, nil
+}
+
+// Remember stores a new cut-off for the user, replacing the cached one.
+func (c *TokenCutoffs) Remember(ctx context.Context, userID string, notBefore time.Time) error {
+	return c.rdb.Set(ctx, cutoffKey(userID), unixOrNone(notBefore), c.ttl).Err()
+}
+
+func unixOrNone(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func unixOrZero(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
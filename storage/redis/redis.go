@@ -0,0 +1,17 @@
+package redis
+
+import (
+	"Auth-Service/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func ConnectionRedis(cnf config.RedisConfig) *redis.Client {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cnf.Addr,
+		Password: cnf.Password,
+		DB:       cnf.DB,
+	})
+	rdb.AddHook(tracingHook{addr: cnf.Addr})
+	return rdb
+}
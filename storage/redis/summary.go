@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"Auth-Service/canonical"
+	pb "Auth-Service/genproto/users"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/proto"
+)
+
+// SummarySource looks users up for good.
+type SummarySource interface {
+	BatchGetUsers(ctx context.Context, request *pb.BatchGetUsersRequest) (*pb.BatchGetUsersResponse, error)
+}
+
+// UserSummaries caches user summaries by id in front of a SummarySource.
+// Usernames always go to the source, since they can change hands, but the
+// users found by them are cached by id as well.
+type UserSummaries struct {
+	rdb    *redis.Client
+	source SummarySource
+	ttl    time.Duration
+}
+
+func NewUserSummaries(rdb *redis.Client, source SummarySource, ttl time.Duration) *UserSummaries {
+	return &UserSummaries{rdb: rdb, source: source, ttl: ttl}
+}
+
+func summaryKey(userID string) string {
+	return "user_summary:" + userID
+}
+
+// BatchGetUsers answers the ids it has cached and asks the source, in one
+// call, for the rest and for the usernames. Ids are looked up by their
+// canonical form, so each user has a single cache entry. An unreachable
+// Redis counts as an empty cache.
+func (s *UserSummaries) BatchGetUsers(ctx context.Context, request *pb.BatchGetUsersRequest) (*pb.BatchGetUsersResponse, error) {
+	response := &pb.BatchGetUsersResponse{Users: []*pb.UserSummary{}}
+	seen := make(map[string]bool)
+	ids := canonical.UserIDs(request.Ids)
+	misses := ids
+	if len(ids) > 0 {
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = summaryKey(id)
+		}
+		if cached, err := s.rdb.MGet(ctx, keys...).Result(); err == nil {
+			misses = nil
+			for i, v := range cached {
+				var u pb.UserSummary
+				data, ok := v.(string)
+				if !ok || proto.Unmarshal([]byte(data), &u) != nil {
+					misses = append(misses, ids[i])
+					continue
+				}
+				if !seen[u.Id] {
+					seen[u.Id] = true
+					response.Users = append(response.Users, &u)
+				}
+			}
+		}
+	}
+	if len(misses) == 0 && len(request.Usernames) == 0 {
+		return response, nil
+	}
+
+	found, err := s.source.BatchGetUsers(ctx, &pb.BatchGetUsersRequest{Ids: misses, Usernames: request.Usernames})
+	if err != nil {
+		return nil, err
+	}
+	pipe := s.rdb.Pipeline()
+	for _, u := range found.Users {
+		if data, err := proto.Marshal(u); err == nil {
+			pipe.Set(ctx, summaryKey(u.Id), data, s.ttl)
+		}
+		if !seen[u.Id] {
+			seen[u.Id] = true
+			response.Users = append(response.Users, u)
+		}
+	}
+	// Caching is best effort; the answer is complete either way.
+	_, _ = pipe.Exec(ctx)
+
+	response.MissingIds = found.MissingIds
+	response.MissingUsernames = found.MissingUsernames
+	return response, nil
+}
+
+// Forget drops the cached summaries of the users.
+func (s *UserSummaries) Forget(ctx context.Context, userIDs ...string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = summaryKey(id)
+	}
+	return s.rdb.Del(ctx, keys...).Err()
+}
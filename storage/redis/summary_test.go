@@ -0,0 +1,154 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "Auth-Service/genproto/users"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeSource answers from a fixed set of users and records what it was asked.
+type fakeSource struct {
+	users    []*pb.UserSummary
+	requests []*pb.BatchGetUsersRequest
+}
+
+func (f *fakeSource) BatchGetUsers(_ context.Context, request *pb.BatchGetUsersRequest) (*pb.BatchGetUsersResponse, error) {
+	f.requests = append(f.requests, request)
+	response := &pb.BatchGetUsersResponse{}
+	for _, u := range f.users {
+		matched := false
+		for _, id := range request.Ids {
+			matched = matched || id == u.Id
+		}
+		for _, name := range request.Usernames {
+			matched = matched || name == u.Username
+		}
+		if matched {
+			response.Users = append(response.Users, u)
+		}
+	}
+	return response, nil
+}
+
+var (
+	alice = &pb.UserSummary{Id: "u1", Username: "alice", FullName: "Alice"}
+	bob   = &pb.UserSummary{Id: "u2", Username: "bob", FullName: "Bob"}
+)
+
+func newTestSummaries(t *testing.T, source SummarySource) (*UserSummaries, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return NewUserSummaries(rdb, source, time.Minute), mr
+}
+
+func cache(t *testing.T, mr *miniredis.Miniredis, u *pb.UserSummary) {
+	data, err := proto.Marshal(u)
+	require.NoError(t, err)
+	require.NoError(t, mr.Set(summaryKey(u.Id), string(data)))
+}
+
+func usernames(users []*pb.UserSummary) []string {
+	names := make([]string, len(users))
+	for i, u := range users {
+		names[i] = u.Username
+	}
+	return names
+}
+
+func TestBatchGetUsersPartialCacheHit(t *testing.T) {
+	source := &fakeSource{users: []*pb.UserSummary{alice, bob}}
+	summaries, mr := newTestSummaries(t, source)
+	cache(t, mr, alice)
+
+	resp, err := summaries.BatchGetUsers(context.Background(), &pb.BatchGetUsersRequest{Ids: []string{"u1", "u2"}})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, usernames(resp.Users))
+	require.Len(t, source.requests, 1)
+	assert.Equal(t, []string{"u2"}, source.requests[0].Ids)
+	assert.True(t, mr.Exists(summaryKey("u2")))
+	assert.Equal(t, time.Minute, mr.TTL(summaryKey("u2")))
+}
+
+func TestBatchGetUsersSameUserByIDAndUsername(t *testing.T) {
+	source := &fakeSource{users: []*pb.UserSummary{alice}}
+	summaries, mr := newTestSummaries(t, source)
+	cache(t, mr, alice)
+
+	resp, err := summaries.BatchGetUsers(context.Background(), &pb.BatchGetUsersRequest{
+		Ids:       []string{"u1"},
+		Usernames: []string{"alice"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, usernames(resp.Users))
+	require.Len(t, source.requests, 1)
+	assert.Empty(t, source.requests[0].Ids)
+	assert.Equal(t, []string{"alice"}, source.requests[0].Usernames)
+}
+
+func TestBatchGetUsersCorruptCacheEntry(t *testing.T) {
+	source := &fakeSource{users: []*pb.UserSummary{alice}}
+	summaries, mr := newTestSummaries(t, source)
+	require.NoError(t, mr.Set(summaryKey("u1"), "\xff not a summary"))
+
+	resp, err := summaries.BatchGetUsers(context.Background(), &pb.BatchGetUsersRequest{Ids: []string{"u1"}})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, usernames(resp.Users))
+	require.Len(t, source.requests, 1)
+	assert.Equal(t, []string{"u1"}, source.requests[0].Ids)
+
+	data, err := mr.Get(summaryKey("u1"))
+	require.NoError(t, err)
+	var cached pb.UserSummary
+	require.NoError(t, proto.Unmarshal([]byte(data), &cached))
+	assert.Equal(t, "alice", cached.Username)
+}
+
+func TestBatchGetUsersRedisUnreachable(t *testing.T) {
+	source := &fakeSource{users: []*pb.UserSummary{alice, bob}}
+	summaries, mr := newTestSummaries(t, source)
+	mr.Close()
+
+	resp, err := summaries.BatchGetUsers(context.Background(), &pb.BatchGetUsersRequest{Ids: []string{"u1", "u2"}})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, usernames(resp.Users))
+	require.Len(t, source.requests, 1)
+	assert.Equal(t, []string{"u1", "u2"}, source.requests[0].Ids)
+}
+
+func TestBatchGetUsersUpperCaseID(t *testing.T) {
+	const id = "0b6e5ab4-3f3c-4c1e-9c47-2f8f0c7d9a10"
+	carol := &pb.UserSummary{Id: id, Username: "carol"}
+	source := &fakeSource{users: []*pb.UserSummary{carol}}
+	summaries, mr := newTestSummaries(t, source)
+
+	resp, err := summaries.BatchGetUsers(context.Background(), &pb.BatchGetUsersRequest{
+		Ids: []string{"0B6E5AB4-3F3C-4C1E-9C47-2F8F0C7D9A10", "{" + id + "}"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"carol"}, usernames(resp.Users))
+	assert.Empty(t, resp.MissingIds)
+	require.Len(t, source.requests, 1)
+	assert.Equal(t, []string{id}, source.requests[0].Ids)
+	assert.Equal(t, []string{summaryKey(id)}, mr.Keys())
+
+	// The next call, in any spelling, is answered from the same entry.
+	resp, err = summaries.BatchGetUsers(context.Background(), &pb.BatchGetUsersRequest{Ids: []string{"urn:uuid:" + id}})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"carol"}, usernames(resp.Users))
+	assert.Len(t, source.requests, 1)
+}
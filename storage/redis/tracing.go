@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("Auth-Service/storage/redis")
+
+// tracingHook wraps every Redis command and pipeline in a client span. Only
+// the command name is recorded, never its arguments, since keys and values
+// may contain tokens.
+type tracingHook struct {
+	addr string
+}
+
+func (h tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := h.start(ctx, cmd.Name(), cmd.Name())
+		defer span.End()
+
+		err := next(ctx, cmd)
+		record(span, err)
+		return err
+	}
+}
+
+func (h tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = cmd.Name()
+		}
+		ctx, span := h.start(ctx, "pipeline", strings.Join(names, " "))
+		defer span.End()
+
+		err := next(ctx, cmds)
+		record(span, err)
+		return err
+	}
+}
+
+func (h tracingHook) start(ctx context.Context, name, statement string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "redis "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemRedis,
+			semconv.DBStatement(statement),
+			attribute.String("server.address", h.addr),
+		),
+	)
+}
+
+func record(span trace.Span, err error) {
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
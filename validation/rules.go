@@ -0,0 +1,162 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"Auth-Service/moderation"
+	"Auth-Service/notification"
+	"Auth-Service/reserved"
+	"Auth-Service/search"
+
+	"github.com/google/uuid"
+)
+
+// StringRule returns a description of the problem with v, or "" if v is
+// acceptable.
+type StringRule func(v string) string
+
+// IntRule is the integer counterpart of StringRule.
+type IntRule func(v int64) string
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_](?:[A-Za-z0-9_.]*[A-Za-z0-9_])?$`)
+
+const (
+	UsernameMinLen = 3
+	UsernameMaxLen = 30
+	FullNameMaxLen = 100
+	BioMaxLen      = 500
+	EmailMaxLen    = 254
+	MaxPageSize    = 100
+	// ReportTextMaxLen bounds report details and moderator notes.
+	ReportTextMaxLen = 1000
+	// SearchQueryMaxLen bounds user search queries.
+	SearchQueryMaxLen = 100
+	// BatchMaxUsers bounds the ids and usernames of one batch lookup.
+	BatchMaxUsers = 100
+)
+
+func Required(v string) string {
+	if strings.TrimSpace(v) == "" {
+		return "is required"
+	}
+	return ""
+}
+
+func MaxLen(n int) StringRule {
+	return func(v string) string {
+		if utf8.RuneCountInString(v) > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+		return ""
+	}
+}
+
+// Username allows ASCII letters, digits, underscores and inner dots.
+func Username(v string) string {
+	if n := utf8.RuneCountInString(v); n < UsernameMinLen || n > UsernameMaxLen {
+		return fmt.Sprintf("must be between %d and %d characters", UsernameMinLen, UsernameMaxLen)
+	}
+	if !usernamePattern.MatchString(v) {
+		return "may contain only letters, digits, underscores and dots, and cannot start or end with a dot"
+	}
+	return ""
+}
+
+// NotReserved rejects usernames on the reserved list.
+func NotReserved(v string) string {
+	if reserved.IsReserved(v) {
+		return "is not available"
+	}
+	return ""
+}
+
+// Email accepts a bare RFC 5322 address without a display name.
+func Email(v string) string {
+	if len(v) > EmailMaxLen {
+		return fmt.Sprintf("must be at most %d characters", EmailMaxLen)
+	}
+	addr, err := mail.ParseAddress(v)
+	if err != nil || addr.Name != "" || addr.Address != v {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+// NotificationType accepts the event types of package notification.
+func NotificationType(v string) string {
+	if !notification.Known(v) {
+		return fmt.Sprintf("must be one of %s", strings.Join(notification.Types(), ", "))
+	}
+	return ""
+}
+
+// ReportReason accepts the report reasons of package moderation.
+func ReportReason(v string) string {
+	if !moderation.KnownReason(v) {
+		return fmt.Sprintf("must be one of %s", strings.Join(moderation.Reasons(), ", "))
+	}
+	return ""
+}
+
+// ReportStatus accepts the report statuses of package moderation.
+func ReportStatus(v string) string {
+	if !moderation.KnownStatus(v) {
+		return fmt.Sprintf("must be one of %s", strings.Join(moderation.Statuses(), ", "))
+	}
+	return ""
+}
+
+// ModerationAction accepts the actions of package moderation.
+func ModerationAction(v string) string {
+	if !moderation.KnownAction(v) {
+		return fmt.Sprintf("must be one of %s", strings.Join(moderation.Actions(), ", "))
+	}
+	return ""
+}
+
+// UserSort accepts the sort orders of package search.
+func UserSort(v string) string {
+	if !search.KnownSort(v) {
+		return fmt.Sprintf("must be one of %s", strings.Join(search.Sorts(), ", "))
+	}
+	return ""
+}
+
+// NotEqual rejects other with msg.
+func NotEqual(other, msg string) StringRule {
+	return func(v string) string {
+		if v == other {
+			return msg
+		}
+		return ""
+	}
+}
+
+func UUID(v string) string {
+	if _, err := uuid.Parse(v); err != nil {
+		return "must be a valid UUID"
+	}
+	return ""
+}
+
+func Min(n int64) IntRule {
+	return func(v int64) string {
+		if v < n {
+			return fmt.Sprintf("must be at least %d", n)
+		}
+		return ""
+	}
+}
+
+func Max(n int64) IntRule {
+	return func(v int64) string {
+		if v > n {
+			return fmt.Sprintf("must be at most %d", n)
+		}
+		return ""
+	}
+}
@@ -0,0 +1,318 @@
+// Package validation checks incoming requests for both transports. The rules
+// for every request message are declared in one table below; HTTP handlers
+// call Request after binding and the gRPC server runs it as an interceptor.
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"Auth-Service/apperrors"
+	pb "Auth-Service/genproto/users"
+	"Auth-Service/moderation"
+	"Auth-Service/password"
+	"Auth-Service/search"
+
+	"google.golang.org/grpc"
+)
+
+type field struct {
+	name  string
+	check func() []string
+}
+
+func firstOf[T any](v T, rules []func(T) string) []string {
+	for _, rule := range rules {
+		if msg := rule(v); msg != "" {
+			return []string{msg}
+		}
+	}
+	return nil
+}
+
+func str(name, v string, rules ...StringRule) field {
+	fns := make([]func(string) string, len(rules))
+	for i, r := range rules {
+		fns[i] = r
+	}
+	return field{name: name, check: func() []string { return firstOf(v, fns) }}
+}
+
+// optional applies rules only when v is non-empty.
+func optional(name, v string, rules ...StringRule) field {
+	if v == "" {
+		return field{name: name, check: func() []string { return nil }}
+	}
+	return str(name, v, rules...)
+}
+
+func num(name string, v int64, rules ...IntRule) field {
+	fns := make([]func(int64) string, len(rules))
+	for i, r := range rules {
+		fns[i] = r
+	}
+	return field{name: name, check: func() []string { return firstOf(v, fns) }}
+}
+
+// each applies rules to every element of vs, naming fields name[i].
+func each(name string, vs []string, rules ...StringRule) []field {
+	fields := make([]field, len(vs))
+	for i, v := range vs {
+		fields[i] = str(fmt.Sprintf("%s[%d]", name, i), v, rules...)
+	}
+	return fields
+}
+
+// newPassword checks v against the password policy, reporting every broken
+// rule rather than only the first.
+func newPassword(name, v string, id password.Identity) field {
+	return field{name: name, check: func() []string {
+		if v == "" {
+			return []string{"is required"}
+		}
+		return password.Violations(v, id)
+	}}
+}
+
+func fieldsOf(req any) []field {
+	switch r := req.(type) {
+	case *pb.RegisterRequest:
+		return []field{
+			str("username", r.Username, Required, Username, NotReserved),
+			str("email", r.Email, Required, Email),
+			newPassword("password", r.Password, password.Identity{Username: r.Username, Email: r.Email}),
+			str("full_name", r.FullName, Required, MaxLen(FullNameMaxLen)),
+		}
+	case *pb.CheckUsernameRequest:
+		return []field{str("username", r.Username, Required, Username)}
+	case *pb.ChangeUsernameRequest:
+		return []field{
+			str("user_id", r.UserId, UUID),
+			str("username", r.Username, Required, Username, NotReserved),
+		}
+	case *pb.ResolveUsernameRequest:
+		return []field{str("username", r.Username, Required, Username)}
+	case *pb.GetUserByUsernameRequest:
+		return []field{
+			str("username", r.Username, Required, Username),
+			optional("viewer_id", r.ViewerId, UUID),
+		}
+	case *pb.RequestEmailChangeRequest:
+		return []field{
+			str("user_id", r.UserId, UUID),
+			str("new_email", r.NewEmail, Required, Email),
+			str("password", r.Password, Required),
+		}
+	case *pb.EmailChangeTokenRequest:
+		return []field{str("token", r.Token, Required)}
+	case *pb.LoginRequest:
+		return []field{
+			{name: "username", check: func() []string {
+				if Required(r.Username) != "" && Required(r.Email) != "" {
+					return []string{"username or email is required"}
+				}
+				return nil
+			}},
+			str("password", r.Password, Required),
+		}
+	case *pb.CheckRefreshTokenRequest:
+		return []field{str("refresh_token", r.RefreshToken, Required)}
+	case *pb.RefreshRequest:
+		return []field{str("refresh_token", r.RefreshToken, Required)}
+	case *pb.ProfileRequest:
+		return []field{
+			str("user_id", r.UserId, UUID),
+			optional("viewer_id", r.ViewerId, UUID),
+		}
+	case *pb.UpdateProfileRequest:
+		return []field{
+			str("user_id", r.Id, UUID),
+			{name: "full_name", check: func() []string {
+				if r.FullName == nil {
+					return nil
+				}
+				return firstOf(*r.FullName, []func(string) string{Required, MaxLen(FullNameMaxLen)})
+			}},
+			str("bio", r.GetBio(), MaxLen(BioMaxLen)),
+			num("countries_visited", int64(r.GetCountriesVisited()), Min(0)),
+		}
+	case *pb.GetUsersRequest:
+		return []field{
+			num("limit", int64(r.Limit), Min(0), Max(MaxPageSize)),
+			num("offset", int64(r.Offset), Min(0)),
+			str("query", r.Query, MaxLen(SearchQueryMaxLen)),
+			num("min_countries_visited", int64(r.MinCountriesVisited), Min(0)),
+			optional("sort", r.Sort, UserSort),
+			{name: "sort", check: func() []string {
+				if r.Sort == search.SortRelevance && strings.TrimSpace(r.Query) == "" {
+					return []string{"relevance requires a query"}
+				}
+				return nil
+			}},
+			{name: "cursor", check: func() []string {
+				if r.Cursor == "" {
+					return nil
+				}
+				c, err := search.DecodeCursor(r.Cursor)
+				switch {
+				case err != nil:
+					return []string{"is not a valid cursor"}
+				case r.Offset != 0:
+					return []string{"cannot be combined with offset"}
+				case c.Sort != r.Sort && !(r.Sort == "" && c.Sort == search.DefaultSort(r.Query)):
+					return []string{"belongs to a different sort order"}
+				}
+				return nil
+			}},
+			optional("viewer_id", r.ViewerId, UUID),
+		}
+	case *pb.BatchGetUsersRequest:
+		fields := []field{{name: "ids", check: func() []string {
+			switch n := len(r.Ids) + len(r.Usernames); {
+			case n == 0:
+				return []string{"ids or usernames are required"}
+			case n > BatchMaxUsers:
+				return []string{fmt.Sprintf("at most %d ids and usernames together", BatchMaxUsers)}
+			}
+			return nil
+		}}}
+		fields = append(fields, each("ids", r.Ids, UUID)...)
+		return append(fields, each("usernames", r.Usernames, Required, MaxLen(UsernameMaxLen))...)
+	case *pb.DeleteUserRequest:
+		return []field{str("user_id", r.Id, UUID)}
+	case *pb.ChangePasswordRequest:
+		return []field{
+			str("user_id", r.UserId, UUID),
+			str("current_password", r.CurrentPassword, Required),
+			str("new_password", r.NewPassword, Required),
+		}
+	case *pb.ConfirmPasswordResetRequest:
+		return []field{
+			str("token", r.Token, Required),
+			str("new_password", r.NewPassword, Required),
+		}
+	case *pb.ResetPasswordRequest:
+		return []field{str("email", r.Email, Required, Email)}
+	case *pb.LogoutRequest:
+		return []field{str("user_id", r.UserId, UUID)}
+	case *pb.ActivityRequest:
+		return []field{str("user_id", r.UserId, UUID)}
+	case *pb.FollowRequest:
+		return []field{
+			str("follower_id", r.FollowerId, UUID),
+			str("user_id", r.FollowingId, UUID, NotEqual(r.FollowerId, "cannot be yourself")),
+		}
+	case *pb.ListNotificationsRequest:
+		return []field{
+			str("user_id", r.UserId, UUID),
+			num("page", int64(r.Page), Min(0)),
+			num("limit", int64(r.Limit), Min(0), Max(MaxPageSize)),
+		}
+	case *pb.MarkNotificationsReadRequest:
+		return append([]field{str("user_id", r.UserId, UUID)}, each("ids", r.Ids, UUID)...)
+	case *pb.NotificationPreferencesRequest:
+		return []field{str("user_id", r.UserId, UUID)}
+	case *pb.UpdateNotificationPreferencesRequest:
+		fields := []field{str("user_id", r.UserId, UUID)}
+		for i, p := range r.Preferences {
+			fields = append(fields, str(fmt.Sprintf("preferences[%d].type", i), p.GetType(), Required, NotificationType))
+		}
+		return fields
+	case *pb.FollowersRequest:
+		return []field{
+			str("user_id", r.UserId, UUID),
+			optional("viewer_id", r.ViewerId, UUID),
+			num("page", int64(r.Page), Min(0)),
+			num("limit", int64(r.Limit), Min(0), Max(MaxPageSize)),
+		}
+	case *pb.FollowRequestsRequest:
+		return []field{
+			str("user_id", r.UserId, UUID),
+			num("page", int64(r.Page), Min(0)),
+			num("limit", int64(r.Limit), Min(0), Max(MaxPageSize)),
+		}
+	case *pb.FollowRequestAction:
+		return []field{
+			str("user_id", r.UserId, UUID),
+			str("requester_id", r.RequesterId, UUID),
+		}
+	case *pb.RelationshipRequest:
+		return []field{
+			str("user_id", r.UserId, UUID),
+			str("target_id", r.TargetId, UUID, NotEqual(r.UserId, "cannot be yourself")),
+		}
+	case *pb.IsBlockedRequest:
+		return []field{
+			str("user_id", r.UserId, UUID),
+			str("other_id", r.OtherId, UUID),
+		}
+	case *pb.UserListRequest:
+		return []field{
+			str("user_id", r.UserId, UUID),
+		}
+	case *pb.ReportUserRequest:
+		return []field{
+			str("reporter_id", r.ReporterId, UUID),
+			str("user_id", r.UserId, UUID, NotEqual(r.ReporterId, "cannot be yourself")),
+			str("reason", r.Reason, Required, ReportReason),
+			str("details", r.Details, MaxLen(ReportTextMaxLen)),
+		}
+	case *pb.ListReportsRequest:
+		return []field{
+			str("moderator_id", r.ModeratorId, UUID),
+			optional("status", r.Status, ReportStatus),
+			num("page", int64(r.Page), Min(0)),
+			num("limit", int64(r.Limit), Min(0), Max(MaxPageSize)),
+		}
+	case *pb.ClaimReportRequest:
+		return []field{
+			str("moderator_id", r.ModeratorId, UUID),
+			str("report_id", r.ReportId, UUID),
+		}
+	case *pb.ResolveReportRequest:
+		return []field{
+			str("moderator_id", r.ModeratorId, UUID),
+			str("report_id", r.ReportId, UUID),
+			str("action", r.Action, Required, ModerationAction),
+			{name: "suspend_seconds", check: func() []string {
+				if r.Action == moderation.ActionSuspend {
+					return firstOf(r.SuspendSeconds, []func(int64) string{Min(60)})
+				}
+				if r.SuspendSeconds != 0 {
+					return []string{"is only allowed with the suspend action"}
+				}
+				return nil
+			}},
+			str("note", r.Note, MaxLen(ReportTextMaxLen)),
+		}
+	}
+	return nil
+}
+
+// Request validates req against its declared rules and returns an
+// apperrors validation error listing every rejected field. Messages without
+// rules are accepted.
+func Request(req any) error {
+	var violations []apperrors.FieldViolation
+	for _, f := range fieldsOf(req) {
+		for _, msg := range f.check() {
+			violations = append(violations, apperrors.FieldViolation{Field: f.name, Description: msg})
+		}
+	}
+	if len(violations) > 0 {
+		return apperrors.Validation(violations...)
+	}
+	return nil
+}
+
+// UnaryServerInterceptor rejects invalid requests before they reach the
+// service. It must run inside apperrors.UnaryServerInterceptor.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := Request(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
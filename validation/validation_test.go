@@ -0,0 +1,111 @@
+package validation
+
+import (
+	"testing"
+
+	"Auth-Service/apperrors"
+	pb "Auth-Service/genproto/users"
+	"Auth-Service/search"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestValid(t *testing.T) {
+	err := Request(&pb.RegisterRequest{
+		Username: "alice.travels",
+		Email:    "alice@example.com",
+		Password: "Tr4vel-the-World",
+		FullName: "Alice",
+	})
+	assert.NoError(t, err)
+}
+
+func TestRequestListsEveryViolation(t *testing.T) {
+	err := Request(&pb.RegisterRequest{
+		Username: ".a",
+		Email:    "Alice <alice@example.com>",
+		FullName: "Alice",
+	})
+	require.ErrorIs(t, err, apperrors.ErrValidation)
+
+	fields := map[string]string{}
+	for _, f := range apperrors.From(err).Fields {
+		fields[f.Field] = f.Description
+	}
+	assert.Len(t, fields, 3)
+	assert.Contains(t, fields["username"], "between 3 and 30")
+	assert.Equal(t, "must be a valid email address", fields["email"])
+	assert.Equal(t, "is required", fields["password"])
+}
+
+func TestRequestPagination(t *testing.T) {
+	err := Request(&pb.FollowersRequest{
+		UserId: "not-a-uuid",
+		Page:   -1,
+		Limit:  MaxPageSize + 1,
+	})
+	require.Error(t, err)
+	assert.Len(t, apperrors.From(err).Fields, 3)
+}
+
+func TestRequestNotificationPreferences(t *testing.T) {
+	err := Request(&pb.UpdateNotificationPreferencesRequest{
+		UserId: "6f1c1c3e-8d0e-4b8a-9a57-3f5f9a0f4d10",
+		Preferences: []*pb.NotificationPreference{
+			{Type: "new_follower", Email: true},
+			{Type: "newsletter"},
+		},
+	})
+	require.ErrorIs(t, err, apperrors.ErrValidation)
+	fields := apperrors.From(err).Fields
+	require.Len(t, fields, 1)
+	assert.Equal(t, "preferences[1].type", fields[0].Field)
+}
+
+func TestRequestSelfFollow(t *testing.T) {
+	id := "6f1c1c3e-8d0e-4b8a-9a57-3f5f9a0f4d10"
+	err := Request(&pb.FollowRequest{FollowerId: id, FollowingId: id})
+	require.ErrorIs(t, err, apperrors.ErrValidation)
+	assert.Equal(t, "user_id", apperrors.From(err).Fields[0].Field)
+}
+
+func TestRequestResolveReport(t *testing.T) {
+	id := "6f1c1c3e-8d0e-4b8a-9a57-3f5f9a0f4d10"
+	assert.NoError(t, Request(&pb.ResolveReportRequest{ModeratorId: id, ReportId: id, Action: "suspend", SuspendSeconds: 86400}))
+
+	err := Request(&pb.ResolveReportRequest{ModeratorId: id, ReportId: id, Action: "suspend"})
+	require.ErrorIs(t, err, apperrors.ErrValidation)
+	assert.Equal(t, "suspend_seconds", apperrors.From(err).Fields[0].Field)
+
+	err = Request(&pb.ResolveReportRequest{ModeratorId: id, ReportId: id, Action: "warn", SuspendSeconds: 60})
+	require.ErrorIs(t, err, apperrors.ErrValidation)
+	assert.Equal(t, "suspend_seconds", apperrors.From(err).Fields[0].Field)
+}
+
+func TestRequestUserSearchCursor(t *testing.T) {
+	cursor := search.Cursor{Sort: search.SortRelevance, Key: "1.5", ID: "6f1c1c3e-8d0e-4b8a-9a57-3f5f9a0f4d10"}.Encode()
+	assert.NoError(t, Request(&pb.GetUsersRequest{Query: "ali", Cursor: cursor}))
+
+	err := Request(&pb.GetUsersRequest{Query: "ali", Sort: search.SortNewest, Cursor: cursor})
+	require.ErrorIs(t, err, apperrors.ErrValidation)
+	assert.Equal(t, "cursor", apperrors.From(err).Fields[0].Field)
+
+	err = Request(&pb.GetUsersRequest{Sort: search.SortRelevance})
+	require.ErrorIs(t, err, apperrors.ErrValidation)
+	assert.Equal(t, "sort", apperrors.From(err).Fields[0].Field)
+}
+
+func TestRequestUpdateProfileEmptyFullName(t *testing.T) {
+	id := "6f1c1c3e-8d0e-4b8a-9a57-3f5f9a0f4d10"
+	empty := ""
+	assert.NoError(t, Request(&pb.UpdateProfileRequest{Id: id, Bio: &empty}))
+
+	err := Request(&pb.UpdateProfileRequest{Id: id, FullName: &empty})
+	require.ErrorIs(t, err, apperrors.ErrValidation)
+	assert.Equal(t, "full_name", apperrors.From(err).Fields[0].Field)
+}
+
+func TestRequestWithoutRules(t *testing.T) {
+	assert.NoError(t, Request(&pb.Token{}))
+}